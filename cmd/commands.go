@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/kerem-kaynak/llmshark/internal/config"
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+)
+
+// commands are the subcommands run instead of the interactive UI.
+var commands = map[string]func(cfg *config.Config, args []string) error{
+	"rotate-key":      rotateKey,
+	"export-profiles": exportProfiles,
+	"import-profiles": importProfiles,
+	"export":          exportSchemas,
+	"site":            writeSite,
+}
+
+// rotateKey re-encrypts the credential store under a new key. For
+// passphrase-protected stores it asks for a new passphrase, keeping the
+// current one (with a fresh salt) when left empty.
+func rotateKey(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %v", args)
+	}
+
+	store, err := storage.NewCredentialStore(cfg.CredentialsPath)
+	if err != nil {
+		return err
+	}
+
+	if !store.UsesPassphrase() {
+		if err := store.RotateKey(); err != nil {
+			return err
+		}
+		fmt.Println("Credential store key rotated.")
+		return nil
+	}
+
+	current, err := readPassphrase("Current passphrase: ")
+	if err != nil {
+		return err
+	}
+	if err := store.Unlock(current); err != nil {
+		return err
+	}
+
+	next, err := readPassphrase("New passphrase (empty to keep the current one): ")
+	if err != nil {
+		return err
+	}
+	if next == "" {
+		next = current
+	} else {
+		confirm, err := readPassphrase("Confirm new passphrase: ")
+		if err != nil {
+			return err
+		}
+		if confirm != next {
+			return errors.New("passphrases do not match")
+		}
+	}
+
+	if err := store.ChangePassphrase(next); err != nil {
+		return err
+	}
+	fmt.Println("Credential store key rotated.")
+	return nil
+}
+
+// exportProfiles writes the named profiles, or all of them, to a bundle
+// protected by a passphrase of its own.
+func exportProfiles(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export-profiles", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write the bundle to")
+	passwords := fs.Bool("passwords", false, "include passwords in the bundle")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: llmshark export-profiles -o FILE [--passwords] [PROFILE...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("an output file is required (-o FILE)")
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase("Bundle passphrase: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := readPassphrase("Confirm bundle passphrase: ")
+	if err != nil {
+		return err
+	}
+	if confirm != passphrase {
+		return errors.New("passphrases do not match")
+	}
+
+	bundle, err := store.ExportProfiles(fs.Args(), passphrase, *passwords)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, bundle, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Profiles exported to %s.\n", *output)
+	if !*passwords {
+		fmt.Println("Passwords were not included; recipients will need to enter their own.")
+	}
+	return nil
+}
+
+// importProfiles merges the profiles of a bundle into the local store.
+func importProfiles(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import-profiles", flag.ContinueOnError)
+	onConflict := fs.String("on-conflict", string(storage.ConflictSkip), "what to do with profiles that already exist: skip, overwrite or rename")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: llmshark import-profiles [--on-conflict skip|overwrite|rename] FILE")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one bundle file is required")
+	}
+
+	policy := storage.ConflictPolicy(*onConflict)
+	if !slices.Contains(storage.ConflictPolicies, policy) {
+		return fmt.Errorf("invalid conflict policy %q (expected one of %v)", policy, storage.ConflictPolicies)
+	}
+
+	bundle, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase("Bundle passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	result, err := store.ImportProfiles(bundle, passphrase, policy)
+	if err != nil {
+		return err
+	}
+
+	printNames("Added", result.Added)
+	printNames("Overwritten", result.Overwritten)
+	printNames("Skipped (already exist)", result.Skipped)
+	for _, from := range slices.Sorted(maps.Keys(result.Renamed)) {
+		fmt.Printf("Renamed: %s -> %s\n", from, result.Renamed[from])
+	}
+	if len(result.ReferencesRemoved) > 0 {
+		printNames("Password references removed", result.ReferencesRemoved)
+		fmt.Println("Bundles can't bring cmd: or file: password references, since they would run commands or read files on this machine. Set these passwords yourself.")
+	}
+	return nil
+}
+
+func printNames(label string, names []string) {
+	if len(names) > 0 {
+		fmt.Printf("%s: %s\n", label, strings.Join(names, ", "))
+	}
+}
+
+// openStore opens the credential store, asking for its passphrase if it
+// is protected by one.
+func openStore(cfg *config.Config) (*storage.CredentialStore, error) {
+	store, err := storage.NewCredentialStore(cfg.CredentialsPath)
+	if err != nil {
+		return nil, err
+	}
+	if !store.Locked() {
+		return store, nil
+	}
+
+	passphrase, err := readPassphrase("Credential store passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Unlock(passphrase); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func readPassphrase(prompt string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", errors.New("a terminal is required to enter the passphrase")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kerem-kaynak/llmshark/internal/config"
+	"github.com/kerem-kaynak/llmshark/internal/export"
+	"github.com/kerem-kaynak/llmshark/internal/postgres"
+	"github.com/kerem-kaynak/llmshark/internal/secret"
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+)
+
+// exportSchemas writes every schema and table the filters let through, in
+// one of the export formats, without starting the interactive UI.
+func exportSchemas(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formats := make([]string, len(cfg.Formats))
+	for i, e := range cfg.Formats {
+		formats[i] = e.Name()
+	}
+	format := fs.String("format", cfg.Export.Format, "output format or template: "+strings.Join(formats, ", "))
+	templatePath := fs.String("template", "", "template file to render instead of a named format")
+	source := addSourceFlags(fs, cfg)
+	output := fs.String("o", "", "file to write to (default: standard output)")
+	budget := fs.Int("budget", cfg.Export.Budget, "fit the output into this many tokens, 0 for no limit")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: llmshark [--service NAME] [filters] export [--format FORMAT | --template FILE] [--profile NAME] [--budget TOKENS] [--header MODE] [-o FILE]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	var err error
+	var exporter export.Exporter
+	if *templatePath != "" {
+		exporter, err = export.ParseTemplate(*templatePath)
+	} else {
+		exporter, err = cfg.Exporter(*format)
+	}
+	if err != nil {
+		return err
+	}
+
+	doc, err := source.load()
+	if err != nil {
+		return err
+	}
+
+	var out string
+	var dropped []string
+	if *budget > 0 {
+		out, dropped, err = export.Fit(exporter, doc, *budget)
+	} else {
+		out, err = export.String(exporter, doc)
+	}
+	if err != nil {
+		return err
+	}
+	if len(dropped) > 0 {
+		fmt.Fprintf(os.Stderr, "Left out to fit %d tokens: %s\n", *budget, strings.Join(dropped, ", "))
+	}
+	if *output == "" {
+		_, err = io.WriteString(os.Stdout, out)
+		return err
+	}
+	return os.WriteFile(*output, []byte(out), 0644)
+}
+
+// sourceFlags are the flags choosing what the export commands load and how
+// the document is headed.
+type sourceFlags struct {
+	cfg     *config.Config
+	profile *string
+	header  *string
+}
+
+func addSourceFlags(fs *flag.FlagSet, cfg *config.Config) *sourceFlags {
+	return &sourceFlags{
+		cfg:     cfg,
+		profile: fs.String("profile", "", "profile to connect with (default: the last used one)"),
+		header:  fs.String("header", cfg.ExportOptions().Header, "what identifies the output below its title: "+strings.Join(export.HeaderModes, ", ")),
+	}
+}
+
+// load connects, loads every schema and table the filters let through and
+// returns them as a document.
+func (f *sourceFlags) load() (*export.Document, error) {
+	cfg := f.cfg
+	opts := cfg.ExportOptions()
+	if !slices.Contains(export.HeaderModes, *f.header) {
+		return nil, fmt.Errorf("invalid header %q (expected one of %s)", *f.header, strings.Join(export.HeaderModes, ", "))
+	}
+	if *f.header == export.HeaderFixed && opts.HeaderText == "" {
+		return nil, errors.New("the fixed header needs export.header_text in the configuration file")
+	}
+	opts.Header = *f.header
+
+	creds, filters, err := exportConnection(cfg, *f.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	creds, err = secret.ResolveCredentials(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, cfg.Timeouts.Connect)
+	defer cancel()
+	client, err := postgres.NewClient(connectCtx, creds)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	queryCtx, cancel := context.WithTimeout(ctx, cfg.Timeouts.Query)
+	defer cancel()
+	schemas, err := client.GetSchemas(queryCtx, cfg.SchemaFilter(filters))
+	if err != nil {
+		return nil, err
+	}
+	for i := range schemas {
+		schemas[i].Selected = true
+	}
+
+	opts.Database = client.Database()
+	opts.ServerVersion = client.ServerVersion()
+	return export.NewDocument(schemas, opts), nil
+}
+
+// exportConnection picks what to connect to the same way the interactive
+// UI does: the --service flag, then the named or last used profile, then
+// the libpq environment.
+func exportConnection(cfg *config.Config, profileName string) (*storage.Credentials, storage.Filters, error) {
+	if cfg.Service != "" {
+		return postgres.EnvironmentCredentials(cfg.Service), storage.Filters{}, nil
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return nil, storage.Filters{}, err
+	}
+	profiles, lastUsed, err := store.Profiles()
+	if err != nil {
+		return nil, storage.Filters{}, err
+	}
+
+	name := profileName
+	if name == "" {
+		name = lastUsed
+	}
+	if i := slices.IndexFunc(profiles, func(p storage.Profile) bool { return p.Name == name }); i >= 0 {
+		return &profiles[i].Credentials, profiles[i].Filters, nil
+	}
+	if profileName != "" {
+		return nil, storage.Filters{}, fmt.Errorf("no profile named %q", profileName)
+	}
+
+	if creds := postgres.EnvironmentCredentials(""); creds != nil {
+		return creds, storage.Filters{}, nil
+	}
+	return nil, storage.Filters{}, errors.New("no profile or libpq environment to connect with; use --profile or --service")
+}
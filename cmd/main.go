@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 
@@ -9,11 +11,38 @@ import (
 )
 
 func main() {
-	cfg, err := config.Load()
+	service := flag.String("service", "", "connect using a service defined in pg_service.conf")
+
+	var filters config.Filters
+	flag.Func("include-schemas", "comma-separated schema patterns to load", listFlag(&filters.IncludeSchemas))
+	flag.Func("exclude-schemas", "comma-separated schema patterns to skip", listFlag(&filters.ExcludeSchemas))
+	flag.Func("include-tables", "comma-separated table patterns to load", listFlag(&filters.IncludeTables))
+	flag.Func("exclude-tables", "comma-separated table patterns to skip", listFlag(&filters.ExcludeTables))
+	flag.Parse()
+
+	cfg, err := config.Load(filters)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
+	cfg.Service = *service
+
+	if flag.NArg() > 0 {
+		command, ok := commands[flag.Arg(0)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n", flag.Arg(0))
+			os.Exit(2)
+		}
+		err := command(cfg, flag.Args()[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	app, err := ui.NewApp(cfg)
 	if err != nil {
@@ -21,8 +50,19 @@ func main() {
 		os.Exit(1)
 	}
 
-	if _, err := app.Run(); err != nil {
+	final, err := app.Run()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error running application: %v\n", err)
 		os.Exit(1)
 	}
+	fmt.Print(ui.ExitOutput(final))
+}
+
+// listFlag parses a comma-separated flag value into dst. Repeating the
+// flag adds to the list.
+func listFlag(dst *[]string) func(string) error {
+	return func(s string) error {
+		*dst = append(*dst, config.SplitList(s)...)
+		return nil
+	}
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kerem-kaynak/llmshark/internal/config"
+	"github.com/kerem-kaynak/llmshark/internal/site"
+)
+
+// writeSite writes every schema and table the filters let through as a
+// static HTML site.
+func writeSite(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("site", flag.ContinueOnError)
+	source := addSourceFlags(fs, cfg)
+	output := fs.String("o", "schema-site", "directory to write the site to")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: llmshark [--service NAME] [filters] site [--profile NAME] [--header MODE] [-o DIR]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	doc, err := source.load()
+	if err != nil {
+		return err
+	}
+	if err := site.Write(*output, doc); err != nil {
+		return err
+	}
+	fmt.Printf("Site written to %s/index.html\n", *output)
+	return nil
+}
@@ -1,15 +1,51 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+
+	"github.com/kerem-kaynak/llmshark/internal/export"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	CredentialsPath string
+	CredentialsPath string `yaml:"-"`
+
+	// Service, when set, connects using the named pg_service.conf entry
+	// instead of stored credentials.
+	Service string `yaml:"-"`
+
+	// FlagFilters are the filters given on the command line, which take
+	// precedence over those of the profile and the config file.
+	FlagFilters Filters `yaml:"-"`
+
+	// Path is the config file the settings below were read from, empty when
+	// there is none and the defaults apply.
+	Path string `yaml:"-"`
+
+	// Formats are the built-in export formats followed by the templates.
+	Formats []export.Exporter `yaml:"-"`
+
+	// templateDirs are the existing templates directories of the config
+	// locations.
+	templateDirs []string
+
+	Filters    Filters         `yaml:"filters"`
+	Export     Export          `yaml:"export"`
+	Keys       Keys            `yaml:"keys"`
+	Theme      Theme           `yaml:"theme"`
+	Timeouts   Timeouts        `yaml:"timeouts"`
+	Redactions []RedactionRule `yaml:"redact"`
 }
 
-func Load() (*Config, error) {
+// Load reads the configuration, with flagFilters taken from the command
+// line.
+func Load(flagFilters Filters) (*Config, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
@@ -20,7 +56,102 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	return &Config{
-		CredentialsPath: filepath.Join(configDir, "credentials.enc"),
-	}, nil
+	cfg := defaults()
+	cfg.CredentialsPath = filepath.Join(configDir, "credentials.enc")
+	cfg.FlagFilters = flagFilters
+
+	cfg.Path, err = findConfigFile(homeDir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Path != "" {
+		if err := cfg.readFile(cfg.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.templateDirs = templateDirs(homeDir, cfg.Path)
+
+	if err := cfg.validate(); err != nil {
+		if cfg.Path != "" {
+			return nil, fmt.Errorf("invalid configuration in %s:\n%w", cfg.Path, err)
+		}
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// findConfigFile returns the config file to read: the one named by
+// LLMSHARK_CONFIG, otherwise the first of the XDG and ~/.llmshark locations
+// that exists, or "" if there is none.
+func findConfigFile(homeDir string) (string, error) {
+	if path := os.Getenv("LLMSHARK_CONFIG"); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("LLMSHARK_CONFIG: %w", err)
+		}
+		return path, nil
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(homeDir, ".config")
+	}
+
+	for _, path := range []string{
+		filepath.Join(configHome, "llmshark", "config.yaml"),
+		filepath.Join(homeDir, ".llmshark", "config.yaml"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// templateDirs returns the templates directories that exist next to the
+// config file and in the XDG and ~/.llmshark locations.
+func templateDirs(homeDir, configPath string) []string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(homeDir, ".config")
+	}
+
+	candidates := []string{
+		filepath.Join(configHome, "llmshark", "templates"),
+		filepath.Join(homeDir, ".llmshark", "templates"),
+	}
+	if configPath != "" {
+		candidates = append([]string{filepath.Join(filepath.Dir(configPath), "templates")}, candidates...)
+	}
+
+	var dirs []string
+	for _, dir := range candidates {
+		if slices.Contains(dirs, dir) {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Unknown keys are rejected so that typos don't go unnoticed
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+	return nil
 }
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadFrom loads the configuration file with the given contents, from a
+// home directory of its own. files are written next to it.
+func loadFrom(t *testing.T, config string, files map[string]string) (*Config, error) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	dir := filepath.Join(home, "etc")
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LLMSHARK_CONFIG", path)
+	return Load(Filters{})
+}
+
+func TestLoadTemplates(t *testing.T) {
+	cfg, err := loadFrom(t, "export:\n  format: brief\n  templates: [extra]\n", map[string]string{
+		"templates/brief.md.tmpl": "{{ len .Schemas }} schemas",
+		"extra/wide.tmpl":         "{{ range .Schemas }}{{ .Name }}{{ end }}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"markdown", "brief", "wide"} {
+		if _, err := cfg.Exporter(name); err != nil {
+			t.Errorf("Exporter(%q): %v", name, err)
+		}
+	}
+}
+
+func TestLoadRejectsBadTemplates(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"parse error", map[string]string{"templates/brief.tmpl": "{{ range .Schemas }}"}, "brief.tmpl"},
+		{"unknown function", map[string]string{"templates/brief.tmpl": "{{ nosuchfunc }}"}, `function "nosuchfunc" not defined`},
+		{"built-in name", map[string]string{"templates/json.tmpl": "{{ .Version }}"}, `"json" is already used by a built-in format`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFrom(t, "", tt.files)
+			if err == nil {
+				t.Fatal("Load accepted the template")
+			}
+			for _, want := range []string{"invalid configuration in", "export.templates", tt.want} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load error %q doesn't contain %q", err, want)
+				}
+			}
+		})
+	}
+}
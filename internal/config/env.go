@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envOverrides are the settings that can be overridden by environment
+// variables, which take precedence over the config file.
+var envOverrides = []struct {
+	name  string
+	apply func(c *Config, value string) error
+}{
+	{"LLMSHARK_INCLUDE_SCHEMAS", func(c *Config, v string) error {
+		c.Filters.IncludeSchemas = SplitList(v)
+		return nil
+	}},
+	{"LLMSHARK_EXCLUDE_SCHEMAS", func(c *Config, v string) error {
+		c.Filters.ExcludeSchemas = SplitList(v)
+		return nil
+	}},
+	{"LLMSHARK_INCLUDE_TABLES", func(c *Config, v string) error {
+		c.Filters.IncludeTables = SplitList(v)
+		return nil
+	}},
+	{"LLMSHARK_EXCLUDE_TABLES", func(c *Config, v string) error {
+		c.Filters.ExcludeTables = SplitList(v)
+		return nil
+	}},
+	{"LLMSHARK_EXPORT_FORMAT", func(c *Config, v string) error {
+		c.Export.Format = v
+		return nil
+	}},
+	{"LLMSHARK_EXPORT_HEADER", func(c *Config, v string) error {
+		c.Export.Header = v
+		return nil
+	}},
+	{"LLMSHARK_EXPORT_DESCRIPTIONS", func(c *Config, v string) error {
+		return parseBool(v, &c.Export.Descriptions)
+	}},
+	{"LLMSHARK_EXPORT_BUDGET", func(c *Config, v string) error {
+		return parseInt(v, &c.Export.Budget)
+	}},
+	{"LLMSHARK_EXPORT_CLIPBOARD", func(c *Config, v string) error {
+		c.Export.Clipboard = v
+		return nil
+	}},
+	{"LLMSHARK_CONNECT_TIMEOUT", func(c *Config, v string) error {
+		return parseDuration(v, &c.Timeouts.Connect)
+	}},
+	{"LLMSHARK_QUERY_TIMEOUT", func(c *Config, v string) error {
+		return parseDuration(v, &c.Timeouts.Query)
+	}},
+}
+
+func (c *Config) applyEnv() error {
+	for _, override := range envOverrides {
+		value, ok := os.LookupEnv(override.name)
+		if !ok {
+			continue
+		}
+		if err := override.apply(c, value); err != nil {
+			return fmt.Errorf("%s: %w", override.name, err)
+		}
+	}
+	return nil
+}
+
+// SplitList parses a comma-separated list, dropping empty entries.
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseBool(s string, dst *bool) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", s)
+	}
+	*dst = v
+	return nil
+}
+
+func parseInt(s string, dst *int) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a whole number", s)
+	}
+	*dst = v
+	return nil
+}
+
+func parseDuration(s string, dst *time.Duration) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 10s or 1m", s)
+	}
+	*dst = v
+	return nil
+}
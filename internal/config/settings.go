@@ -0,0 +1,346 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kerem-kaynak/llmshark/internal/export"
+	"github.com/kerem-kaynak/llmshark/internal/postgres"
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Filters are the schema and table patterns used by profiles that don't
+// set their own.
+type Filters struct {
+	IncludeSchemas []string `yaml:"include_schemas"`
+	ExcludeSchemas []string `yaml:"exclude_schemas"`
+	IncludeTables  []string `yaml:"include_tables"`
+	ExcludeTables  []string `yaml:"exclude_tables"`
+}
+
+// Export controls what goes into the generated documentation.
+type Export struct {
+	// Format is the exporter used when copying from the explorer.
+	Format string `yaml:"format"`
+	// Header is what identifies the output below its title: "timestamp"
+	// for the generation time, "fingerprint" for the database, server
+	// version and a hash of the exported objects, "fixed" for HeaderText,
+	// or "none".
+	Header     string `yaml:"header"`
+	HeaderText string `yaml:"header_text"`
+	// Descriptions includes table and column comments.
+	Descriptions bool `yaml:"descriptions"`
+	// Budget is the token count copied output is fitted into, 0 for no
+	// limit.
+	Budget int `yaml:"budget"`
+	// Templates are text/template files, or directories of *.tmpl files,
+	// offered as formats besides those in the templates directories.
+	// Relative paths are relative to the config file.
+	Templates []string `yaml:"templates"`
+	// Clipboard is how copies reach the clipboard: "system", "osc52" for
+	// the terminal escape sequence, or "auto" to use OSC 52 over SSH and
+	// when the system clipboard is unavailable.
+	Clipboard string `yaml:"clipboard"`
+}
+
+// Clipboard modes.
+const (
+	ClipboardAuto   = "auto"
+	ClipboardSystem = "system"
+	ClipboardOSC52  = "osc52"
+)
+
+// ClipboardModes lists the accepted export.clipboard values.
+var ClipboardModes = []string{ClipboardAuto, ClipboardSystem, ClipboardOSC52}
+
+// Keys binds explorer actions to keys, in the notation used by Bubble Tea
+// such as "k", "up", "ctrl+d" or "space". Ctrl+C always quits.
+type Keys struct {
+	Up          KeyList `yaml:"up"`
+	Down        KeyList `yaml:"down"`
+	Expand      KeyList `yaml:"expand"`
+	Collapse    KeyList `yaml:"collapse"`
+	Select      KeyList `yaml:"select"`
+	DeselectAll KeyList `yaml:"deselect_all"`
+	Copy        KeyList `yaml:"copy"`
+	Diagram     KeyList `yaml:"diagram"`
+	Save        KeyList `yaml:"save"`
+	Print       KeyList `yaml:"print"`
+	Comment     KeyList `yaml:"comment"`
+	Edit        KeyList `yaml:"edit"`
+	Profiles    KeyList `yaml:"profiles"`
+	Filters     KeyList `yaml:"filters"`
+	Format      KeyList `yaml:"format"`
+	Budget      KeyList `yaml:"budget"`
+	Quit        KeyList `yaml:"quit"`
+}
+
+// KeyList is one or more keys bound to the same action. In the config file
+// it can be written as a single key or a list.
+type KeyList []string
+
+func (k *KeyList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*k = KeyList{node.Value}
+		return nil
+	}
+	var keys []string
+	if err := node.Decode(&keys); err != nil {
+		return err
+	}
+	*k = keys
+	return nil
+}
+
+// Theme holds the interface colors, as ANSI color numbers or #rrggbb.
+type Theme struct {
+	Title    string `yaml:"title"`
+	Selected string `yaml:"selected"`
+	Normal   string `yaml:"normal"`
+	Error    string `yaml:"error"`
+	Info     string `yaml:"info"`
+	Help     string `yaml:"help"`
+	Label    string `yaml:"label"`
+}
+
+type Timeouts struct {
+	// Connect bounds establishing a connection, including any SSH tunnel.
+	Connect time.Duration `yaml:"connect"`
+	// Query bounds each catalog query and comment update.
+	Query time.Duration `yaml:"query"`
+}
+
+// RedactionRule replaces matches of Pattern in descriptions, column
+// defaults and constraint and index definitions before they are exported.
+type RedactionRule struct {
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+
+	re *regexp.Regexp
+}
+
+const defaultReplacement = "[REDACTED]"
+
+func defaults() *Config {
+	return &Config{
+		Export: Export{
+			Format:       export.DefaultFormat(),
+			Header:       export.HeaderTimestamp,
+			Descriptions: true,
+			Clipboard:    ClipboardAuto,
+		},
+		Keys: Keys{
+			Up:          KeyList{"up", "k"},
+			Down:        KeyList{"down", "j"},
+			Expand:      KeyList{"right", "l", "enter"},
+			Collapse:    KeyList{"left", "h"},
+			Select:      KeyList{"space"},
+			DeselectAll: KeyList{"d"},
+			Copy:        KeyList{"m"},
+			Diagram:     KeyList{"g"},
+			Save:        KeyList{"s"},
+			Print:       KeyList{"x"},
+			Comment:     KeyList{"c"},
+			Edit:        KeyList{"e"},
+			Profiles:    KeyList{"p"},
+			Filters:     KeyList{"f"},
+			Format:      KeyList{"o"},
+			Budget:      KeyList{"b"},
+			Quit:        KeyList{"q"},
+		},
+		Theme: Theme{
+			Title:    "39",
+			Selected: "205",
+			Normal:   "252",
+			Error:    "196",
+			Info:     "86",
+			Help:     "241",
+			Label:    "111",
+		},
+		Timeouts: Timeouts{
+			Connect: 5 * time.Second,
+			Query:   30 * time.Second,
+		},
+	}
+}
+
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|#[0-9a-fA-F]{3}|[0-9]{1,3})$`)
+
+// validate checks every setting, reporting all problems at once.
+func (c *Config) validate() error {
+	var errs []error
+
+	if c.Timeouts.Connect <= 0 {
+		errs = append(errs, fmt.Errorf("timeouts.connect must be positive, got %s", c.Timeouts.Connect))
+	}
+	if c.Timeouts.Query <= 0 {
+		errs = append(errs, fmt.Errorf("timeouts.query must be positive, got %s", c.Timeouts.Query))
+	}
+
+	if err := c.loadFormats(); err != nil {
+		errs = append(errs, fmt.Errorf("export.templates: %w", err))
+	}
+	if _, err := c.Exporter(c.Export.Format); err != nil {
+		errs = append(errs, fmt.Errorf("export.format: %w", err))
+	}
+	switch {
+	case !slices.Contains(export.HeaderModes, c.Export.Header):
+		errs = append(errs, fmt.Errorf("export.header: invalid mode %q (expected one of %s)", c.Export.Header, strings.Join(export.HeaderModes, ", ")))
+	case c.Export.Header == export.HeaderFixed && strings.TrimSpace(c.Export.HeaderText) == "":
+		errs = append(errs, errors.New("export.header_text is required with the fixed header"))
+	}
+	if c.Export.Budget < 0 {
+		errs = append(errs, fmt.Errorf("export.budget must not be negative, got %d", c.Export.Budget))
+	}
+	if !slices.Contains(ClipboardModes, c.Export.Clipboard) {
+		errs = append(errs, fmt.Errorf("export.clipboard: invalid mode %q (expected one of %s)", c.Export.Clipboard, strings.Join(ClipboardModes, ", ")))
+	}
+
+	errs = append(errs, c.Keys.validate()...)
+	errs = append(errs, c.Theme.validate()...)
+
+	for i := range c.Redactions {
+		rule := &c.Redactions[i]
+		if rule.Pattern == "" {
+			errs = append(errs, fmt.Errorf("redact[%d]: pattern is required", i))
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("redact[%d]: %w", i, err))
+			continue
+		}
+		rule.re = re
+		if rule.Replacement == "" {
+			rule.Replacement = defaultReplacement
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate reports actions without keys and keys bound to several actions.
+func (k *Keys) validate() []error {
+	var errs []error
+	actions := map[string]string{}
+
+	v := reflect.ValueOf(*k)
+	for i := range v.NumField() {
+		action := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		keys := v.Field(i).Interface().(KeyList)
+		if len(keys) == 0 {
+			errs = append(errs, fmt.Errorf("keys.%s: at least one key is required", action))
+		}
+		for _, key := range keys {
+			if other, ok := actions[key]; ok {
+				errs = append(errs, fmt.Errorf("keys.%s: %q is already bound to %s", action, key, other))
+				continue
+			}
+			actions[key] = action
+		}
+	}
+	return errs
+}
+
+func (t *Theme) validate() []error {
+	var errs []error
+
+	v := reflect.ValueOf(*t)
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Tag.Get("yaml")
+		color := v.Field(i).String()
+		if !colorPattern.MatchString(color) {
+			errs = append(errs, fmt.Errorf("theme.%s: %q is not an ANSI color number or #rrggbb", name, color))
+		}
+	}
+	return errs
+}
+
+// Redact applies the redaction rules to s.
+func (c *Config) Redact(s string) string {
+	for _, rule := range c.Redactions {
+		s = rule.re.ReplaceAllString(s, rule.Replacement)
+	}
+	return s
+}
+
+// loadFormats sets Formats to the built-in formats and the templates.
+func (c *Config) loadFormats() error {
+	paths := slices.Clone(c.templateDirs)
+	for _, path := range c.Export.Templates {
+		path = storage.ExpandHome(path)
+		if !filepath.IsAbs(path) && c.Path != "" {
+			path = filepath.Join(filepath.Dir(c.Path), path)
+		}
+		paths = append(paths, path)
+	}
+
+	c.Formats = nil
+	for _, name := range export.Formats() {
+		e, _ := export.Lookup(name)
+		c.Formats = append(c.Formats, e)
+	}
+
+	templates, err := export.LoadTemplates(paths)
+	for _, t := range templates {
+		c.Formats = append(c.Formats, t)
+	}
+	return err
+}
+
+// Exporter returns the built-in format or template called name.
+func (c *Config) Exporter(name string) (export.Exporter, error) {
+	for _, e := range c.Formats {
+		if e.Name() == name {
+			return e, nil
+		}
+	}
+	names := make([]string, len(c.Formats))
+	for i, e := range c.Formats {
+		names[i] = e.Name()
+	}
+	return nil, fmt.Errorf("unknown export format %q (expected one of %s)", name, strings.Join(names, ", "))
+}
+
+// ExportOptions returns the export settings, with the redaction rules.
+// Callers fill in the database and server version for the fingerprint
+// header.
+func (c *Config) ExportOptions() export.Options {
+	return export.Options{
+		Header:       c.Export.Header,
+		HeaderText:   c.Export.HeaderText,
+		Descriptions: c.Export.Descriptions,
+		Redact:       c.Redact,
+	}
+}
+
+// SchemaFilter returns the filters for a connection whose profile sets
+// profile. Each list comes from the command line if given there, otherwise
+// from the profile, otherwise from the config file.
+func (c *Config) SchemaFilter(profile storage.Filters) postgres.SchemaFilter {
+	flags, cfg := c.FlagFilters, c.Filters
+
+	return postgres.SchemaFilter{
+		IncludeSchemas: firstList(flags.IncludeSchemas, profile.IncludeSchemas, cfg.IncludeSchemas),
+		ExcludeSchemas: firstList(flags.ExcludeSchemas, profile.ExcludeSchemas, cfg.ExcludeSchemas),
+		IncludeTables:  firstList(flags.IncludeTables, profile.IncludeTables, cfg.IncludeTables),
+		ExcludeTables:  firstList(flags.ExcludeTables, profile.ExcludeTables, cfg.ExcludeTables),
+	}
+}
+
+// firstList returns the first non-empty list.
+func firstList(lists ...[]string) []string {
+	for _, list := range lists {
+		if len(list) > 0 {
+			return list
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/kerem-kaynak/llmshark/internal/tokens"
+)
+
+// ErrOverBudget is returned by Fit when the output doesn't fit even with
+// only key columns left.
+var ErrOverBudget = errors.New("the selection does not fit in the token budget")
+
+// reductions are applied in order, each on top of the previous ones, until
+// the output fits.
+var reductions = []struct {
+	name  string
+	apply func(doc *Document)
+}{
+	{"descriptions", func(doc *Document) {
+		doc.eachTable(func(t *Table) {
+			t.Description = ""
+			for i := range t.Columns {
+				t.Columns[i].Description = ""
+			}
+		})
+	}},
+	{"defaults", func(doc *Document) {
+		doc.eachTable(func(t *Table) {
+			for i := range t.Columns {
+				t.Columns[i].Default = ""
+			}
+		})
+	}},
+	{"constraint names", func(doc *Document) {
+		doc.eachTable(func(t *Table) {
+			for i := range t.Columns {
+				t.Columns[i].Constraints = nil
+			}
+			for i := range t.Constraints {
+				t.Constraints[i].Name = ""
+			}
+		})
+	}},
+}
+
+// Fit renders doc with e, leaving out detail until the output takes at
+// most budget tokens: descriptions first, then defaults, then constraint
+// names, then columns that aren't part of a key, nullable ones and those
+// further down their table first. It returns the output and what was left
+// out. doc itself is not changed.
+func Fit(e Exporter, doc *Document, budget int) (string, []string, error) {
+	out, err := String(e, doc)
+	if err != nil || tokens.Count(out) <= budget {
+		return out, nil, err
+	}
+
+	doc = doc.clone()
+	var dropped []string
+	for _, r := range reductions {
+		r.apply(doc)
+		dropped = append(dropped, r.name)
+
+		out, err = String(e, doc)
+		if err != nil || tokens.Count(out) <= budget {
+			return out, dropped, err
+		}
+	}
+
+	// Find the fewest columns to drop, as each one dropped shrinks the
+	// output further
+	candidates := dropCandidates(doc)
+	render := func(n int) (string, error) {
+		trimmed := doc.clone()
+		trimmed.dropColumns(candidates[:n])
+		return String(e, trimmed)
+	}
+
+	var renderErr error
+	n := sort.Search(len(candidates)+1, func(n int) bool {
+		out, err := render(n)
+		if err != nil {
+			renderErr = err
+			return true
+		}
+		return tokens.Count(out) <= budget
+	})
+	if renderErr != nil {
+		return "", nil, renderErr
+	}
+	if n > len(candidates) {
+		out, err := render(len(candidates))
+		if err != nil {
+			return "", nil, err
+		}
+		return "", nil, fmt.Errorf("%w: %d tokens with only key columns, %d allowed",
+			ErrOverBudget, tokens.Count(out), budget)
+	}
+
+	out, err = render(n)
+	if n > 0 {
+		dropped = append(dropped, fmt.Sprintf("%d columns", n))
+	}
+	return out, dropped, err
+}
+
+type columnRef struct {
+	schema, table int
+	column        string
+}
+
+// dropCandidates returns the columns that aren't part of a key, in the
+// order they are dropped.
+func dropCandidates(doc *Document) []columnRef {
+	type candidate struct {
+		columnRef
+		nullable bool
+		position int
+	}
+
+	var candidates []candidate
+	for i := range doc.Schemas {
+		for j := range doc.Schemas[i].Tables {
+			table := &doc.Schemas[i].Tables[j]
+			for k, col := range table.Columns {
+				if len(table.keyKinds(col.Name)) == 0 {
+					candidates = append(candidates, candidate{columnRef{i, j, col.Name}, col.Nullable, k})
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if a.nullable != b.nullable {
+			if a.nullable {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(b.position, a.position)
+	})
+
+	refs := make([]columnRef, len(candidates))
+	for i, c := range candidates {
+		refs[i] = c.columnRef
+	}
+	return refs
+}
+
+// dropColumns removes columns, along with the constraints and indexes that
+// use them.
+func (doc *Document) dropColumns(columns []columnRef) {
+	drop := make(map[[2]int]map[string]bool)
+	for _, c := range columns {
+		key := [2]int{c.schema, c.table}
+		if drop[key] == nil {
+			drop[key] = make(map[string]bool)
+		}
+		drop[key][c.column] = true
+	}
+
+	for key, names := range drop {
+		table := &doc.Schemas[key[0]].Tables[key[1]]
+		table.Columns = slices.DeleteFunc(table.Columns, func(c Column) bool { return names[c.Name] })
+
+		kept := make(map[string]bool, len(table.Columns))
+		for _, c := range table.Columns {
+			kept[c.Name] = true
+		}
+		table.Constraints = slices.DeleteFunc(table.Constraints, func(c Constraint) bool { return !allSelected(c.Columns, kept) })
+		table.Indexes = slices.DeleteFunc(table.Indexes, func(i Index) bool { return !allSelected(i.Columns, kept) })
+	}
+}
+
+func (doc *Document) eachTable(fn func(t *Table)) {
+	for i := range doc.Schemas {
+		for j := range doc.Schemas[i].Tables {
+			fn(&doc.Schemas[i].Tables[j])
+		}
+	}
+}
+
+// clone copies doc deeply enough for its tables, columns, constraints and
+// indexes to be changed without affecting doc.
+func (doc *Document) clone() *Document {
+	c := *doc
+	c.Schemas = slices.Clone(doc.Schemas)
+	for i := range c.Schemas {
+		c.Schemas[i].Tables = slices.Clone(c.Schemas[i].Tables)
+		for j := range c.Schemas[i].Tables {
+			t := &c.Schemas[i].Tables[j]
+			t.Columns = slices.Clone(t.Columns)
+			t.Constraints = slices.Clone(t.Constraints)
+			t.Indexes = slices.Clone(t.Indexes)
+		}
+	}
+	return &c
+}
@@ -0,0 +1,83 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kerem-kaynak/llmshark/internal/tokens"
+)
+
+func budgetDocument() *Document {
+	doc := &Document{Version: Version}
+	var tables []Table
+	for _, name := range []string{"users", "orders", "invoices"} {
+		table := Table{
+			Name:        name,
+			Description: "All " + name + " of the shop, one row per " + name + " ever created.",
+			Columns: []Column{
+				{Name: "id", Type: "integer", PrimaryKey: true, Identity: "always", Constraints: []string{name + "_pkey"}},
+			},
+			Constraints: []Constraint{
+				{Name: name + "_pkey", Type: PrimaryKey, Columns: []string{"id"}, Definition: "PRIMARY KEY (id)"},
+			},
+		}
+		for i := range 12 {
+			table.Columns = append(table.Columns, Column{
+				Name:        fmt.Sprintf("attribute_%d", i),
+				Type:        "character varying(255)",
+				Description: fmt.Sprintf("Attribute %d of the %s, as entered by the customer.", i, name),
+				Nullable:    i%2 == 0,
+				Default:     "'unknown'::character varying",
+			})
+		}
+		tables = append(tables, table)
+	}
+	doc.Schemas = []Schema{{Name: "public", Tables: tables}}
+	return doc
+}
+
+func TestFitStaysWithinBudget(t *testing.T) {
+	doc := budgetDocument()
+	for _, e := range exporters {
+		full, err := String(e, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fullTokens := tokens.Count(full)
+
+		reduced := 0
+		for budget := fullTokens; budget > 0; budget -= max(1, fullTokens/40) {
+			out, dropped, err := Fit(e, doc, budget)
+			if errors.Is(err, ErrOverBudget) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: Fit(%d): %v", e.Name(), budget, err)
+			}
+			if got := tokens.Count(out); got > budget {
+				t.Errorf("%s: Fit(%d) returned %d tokens, dropping %v", e.Name(), budget, got, dropped)
+			}
+			if budget == fullTokens && (out != full || len(dropped) > 0) {
+				t.Errorf("%s: Fit changed output that fits, dropping %v", e.Name(), dropped)
+			}
+			if budget < fullTokens {
+				reduced++
+			}
+		}
+		if reduced == 0 {
+			t.Errorf("%s: no budget below the full %d tokens could be met", e.Name(), fullTokens)
+		}
+	}
+}
+
+func TestFitOverBudget(t *testing.T) {
+	doc := budgetDocument()
+	_, _, err := Fit(Markdown{}, doc, 10)
+	if !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("Fit with a budget of 10 tokens = %v, want ErrOverBudget", err)
+	}
+	if len(doc.Schemas[0].Tables[0].Columns) != 13 || doc.Schemas[0].Tables[0].Description == "" {
+		t.Error("Fit changed the document it was given")
+	}
+}
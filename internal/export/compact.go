@@ -0,0 +1,169 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Compact renders a document in as few tokens as possible, one line per
+// table in the form
+//
+//	orders(id int PK, user_id int FK→users.id, status varchar NN) -- comments
+type Compact struct{}
+
+func (Compact) Name() string      { return "compact" }
+func (Compact) Extension() string { return "txt" }
+
+// typeAbbreviations shortens the type names format_type produces, longest
+// first so that prefixes don't match early.
+var typeAbbreviations = []struct{ long, short string }{
+	{"timestamp without time zone", "timestamp"},
+	{"timestamp with time zone", "timestamptz"},
+	{"time without time zone", "time"},
+	{"time with time zone", "timetz"},
+	{"character varying", "varchar"},
+	{"double precision", "float8"},
+	{"character", "char"},
+	{"integer", "int"},
+	{"boolean", "bool"},
+}
+
+// compactNote keeps comments on their line.
+var compactNote = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+func (Compact) Export(w io.Writer, doc *Document) error {
+	var b strings.Builder
+
+	for _, line := range doc.HeaderLines() {
+		b.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+
+	for _, enum := range doc.Enums {
+		fmt.Fprintf(&b, "enum %s.%s: %s\n", enum.Schema, enum.Name, strings.Join(enum.Labels, "|"))
+	}
+
+	for _, schema := range doc.Schemas {
+		fmt.Fprintf(&b, "# %s\n", schema.Name)
+		for i := range schema.Tables {
+			b.WriteString(compactTable(doc, schema.Name, &schema.Tables[i]) + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func compactTable(doc *Document, schema string, table *Table) string {
+	var fields, notes []string
+
+	for _, col := range table.Columns {
+		parts := []string{col.Name, compactType(col.Type)}
+		if singleColumnKey(table, PrimaryKey, col.Name) {
+			parts = append(parts, "PK")
+		} else if !col.Nullable {
+			parts = append(parts, "NN")
+		}
+		if singleColumnKey(table, Unique, col.Name) {
+			parts = append(parts, "UK")
+		}
+		for _, fk := range doc.foreignKeys(table) {
+			if len(fk.Columns) == 1 && fk.Columns[0] == col.Name {
+				parts = append(parts, "FK→"+compactRef(schema, fk.References, fk.References.Columns[0]))
+			}
+		}
+
+		switch {
+		case col.Identity != "" || strings.HasPrefix(col.Default, "nextval("):
+			parts = append(parts, "AUTO")
+		case col.Generated != "":
+			parts = append(parts, "AS ("+col.Generated+")")
+		case col.Default != "":
+			parts = append(parts, "="+col.Default)
+		}
+
+		fields = append(fields, strings.Join(parts, " "))
+		if col.Description != "" {
+			notes = append(notes, col.Name+": "+compactNote.Replace(col.Description))
+		}
+	}
+
+	// Keys over several columns follow the columns
+	for _, con := range table.Constraints {
+		if len(con.Columns) < 2 {
+			continue
+		}
+		columns := "(" + strings.Join(con.Columns, ",") + ")"
+		switch {
+		case con.Type == PrimaryKey:
+			fields = append(fields, "PK"+columns)
+		case con.Type == Unique:
+			fields = append(fields, "UK"+columns)
+		case con.Type == ForeignKey && doc.hasColumns(con.References):
+			ref := con.References
+			fields = append(fields, fmt.Sprintf("FK%s→%s(%s)", columns, compactRef(schema, ref, ""), strings.Join(ref.Columns, ",")))
+		}
+	}
+
+	line := fmt.Sprintf("%s(%s)", table.Name, strings.Join(fields, ", "))
+	if table.Description != "" {
+		notes = append([]string{compactNote.Replace(table.Description)}, notes...)
+	}
+	if len(notes) > 0 {
+		line += " -- " + strings.Join(notes, "; ")
+	}
+	return line
+}
+
+// compactRef names the referenced table, and column if given, qualifying
+// the table only when it is in another schema than schema.
+func compactRef(schema string, ref *Reference, column string) string {
+	name := ref.Table
+	if ref.Schema != schema {
+		name = ref.Schema + "." + name
+	}
+	if column != "" {
+		name += "." + column
+	}
+	return name
+}
+
+func singleColumnKey(t *Table, kind, column string) bool {
+	for _, con := range t.Constraints {
+		if con.Type == kind && len(con.Columns) == 1 && con.Columns[0] == column {
+			return true
+		}
+	}
+	return false
+}
+
+// compactType abbreviates t, keeping its modifiers and array brackets.
+// format_type puts the precision of time types after the first word, as in
+// "timestamp(3) without time zone", which becomes "timestamp(3)".
+func compactType(t string) string {
+	for _, a := range typeAbbreviations {
+		head, tail, _ := strings.Cut(a.long, " ")
+		rest, ok := strings.CutPrefix(t, head)
+		if !ok {
+			continue
+		}
+
+		var precision string
+		if strings.HasPrefix(rest, "(") {
+			if end := strings.IndexByte(rest, ')'); end > 0 && tail != "" {
+				precision, rest = rest[:end+1], rest[end+1:]
+			}
+		}
+		if tail != "" {
+			if rest, ok = strings.CutPrefix(rest, " "+tail); !ok {
+				continue
+			}
+		}
+		// Only whole names, not longer ones sharing the prefix
+		if rest != "" && rest[0] != '(' && rest[0] != '[' {
+			continue
+		}
+		return a.short + precision + rest
+	}
+	return t
+}
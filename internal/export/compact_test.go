@@ -0,0 +1,36 @@
+package export
+
+import "testing"
+
+func TestCompactType(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"integer", "int"},
+		{"integer[]", "int[]"},
+		{"boolean", "bool"},
+		{"text", "text"},
+		{"character varying", "varchar"},
+		{"character varying(255)", "varchar(255)"},
+		{"character varying(64)[]", "varchar(64)[]"},
+		{"character(2)", "char(2)"},
+		{"double precision", "float8"},
+		{"timestamp without time zone", "timestamp"},
+		{"timestamp with time zone", "timestamptz"},
+		{"timestamp(3) without time zone", "timestamp(3)"},
+		{"timestamp(6) with time zone", "timestamptz(6)"},
+		{"timestamp(0) with time zone[]", "timestamptz(0)[]"},
+		{"time without time zone", "time"},
+		{"time(6) with time zone", "timetz(6)"},
+		{"time(3) without time zone", "time(3)"},
+		{"numeric(10,2)", "numeric(10,2)"},
+		{"interval", "interval"},
+		{"integer_range", "integer_range"},
+		{"booleans", "booleans"},
+	}
+	for _, tt := range tests {
+		if got := compactType(tt.in); got != tt.want {
+			t.Errorf("compactType(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
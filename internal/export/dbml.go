@@ -0,0 +1,258 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DBML renders a document in the Database Markup Language used by
+// dbdiagram.io: enums, tables with their indexes and notes, and a ref per
+// foreign key.
+type DBML struct{}
+
+func (DBML) Name() string      { return "dbml" }
+func (DBML) Extension() string { return "dbml" }
+
+func (DBML) Export(w io.Writer, doc *Document) error {
+	var b strings.Builder
+
+	for _, enum := range doc.Enums {
+		fmt.Fprintf(&b, "Enum %s {\n", dbmlName(enum.Schema, enum.Name))
+		for _, label := range enum.Labels {
+			fmt.Fprintf(&b, "  %s\n", dbmlIdent(label))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	for _, schema := range doc.Schemas {
+		for i := range schema.Tables {
+			dbmlTable(&b, schema.Name, &schema.Tables[i])
+		}
+	}
+
+	for _, schema := range doc.Schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			for _, fk := range doc.foreignKeys(table) {
+				ref := fk.References
+				// Each row refers to one parent; unique keys allow one child
+				relation := ">"
+				if table.isUnique(fk.Columns) {
+					relation = "-"
+				}
+				name := ""
+				if fk.Name != "" {
+					name = " " + dbmlIdent(fk.Name)
+				}
+				fmt.Fprintf(&b, "Ref%s: %s %s %s\n",
+					name,
+					dbmlColumns(schema.Name, table.Name, fk.Columns),
+					relation,
+					dbmlColumns(ref.Schema, ref.Table, ref.Columns))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n")
+	return err
+}
+
+func dbmlTable(b *strings.Builder, schema string, table *Table) {
+	fmt.Fprintf(b, "Table %s {\n", dbmlName(schema, table.Name))
+
+	// Single-column keys are column settings, the others are indexes
+	var keys []string
+	for _, con := range table.Constraints {
+		if (con.Type == PrimaryKey || con.Type == Unique) && len(con.Columns) > 1 {
+			setting := "pk"
+			if con.Type == Unique {
+				setting = "unique"
+			}
+			if con.Name != "" {
+				setting += ", name: " + dbmlString(con.Name)
+			}
+			keys = append(keys, fmt.Sprintf("%s [%s]", dbmlColumnList(con.Columns), setting))
+		}
+	}
+
+	for _, col := range table.Columns {
+		var settings []string
+		for _, con := range table.Constraints {
+			if len(con.Columns) == 1 && con.Columns[0] == col.Name {
+				switch con.Type {
+				case PrimaryKey:
+					settings = append(settings, "pk")
+				case Unique:
+					settings = append(settings, "unique")
+				}
+			}
+		}
+		if col.Identity != "" || strings.HasPrefix(col.Default, "nextval(") {
+			settings = append(settings, "increment")
+		}
+		if !col.Nullable {
+			settings = append(settings, "not null")
+		}
+		if col.Default != "" {
+			settings = append(settings, "default: `"+col.Default+"`")
+		}
+		if col.Generated != "" {
+			settings = append(settings, "note: "+dbmlString("generated as "+col.Generated+
+				noteSuffix(col.Description)))
+		} else if col.Description != "" {
+			settings = append(settings, "note: "+dbmlString(col.Description))
+		}
+
+		line := fmt.Sprintf("  %s %s", dbmlIdent(col.Name), dbmlType(col.Type))
+		if len(settings) > 0 {
+			line += " [" + strings.Join(settings, ", ") + "]"
+		}
+		b.WriteString(line + "\n")
+	}
+
+	for _, index := range table.Indexes {
+		if entry, ok := dbmlIndex(index); ok {
+			keys = append(keys, entry)
+		}
+	}
+	if len(keys) > 0 {
+		b.WriteString("\n  indexes {\n")
+		for _, key := range keys {
+			fmt.Fprintf(b, "    %s\n", key)
+		}
+		b.WriteString("  }\n")
+	}
+
+	if table.Description != "" {
+		fmt.Fprintf(b, "\n  Note: %s\n", dbmlString(table.Description))
+	}
+	b.WriteString("}\n\n")
+}
+
+func noteSuffix(description string) string {
+	if description == "" {
+		return ""
+	}
+	return "\n" + description
+}
+
+// indexDefinition splits a CREATE INDEX statement into its access method
+// and the parenthesized key list.
+var indexDefinition = regexp.MustCompile(` USING (\w+) \(`)
+
+// dbmlIndex converts an index to a DBML index entry, with key expressions
+// in backticks. Indexes it can't parse are left out.
+func dbmlIndex(index Index) (string, bool) {
+	loc := indexDefinition.FindStringSubmatchIndex(index.Definition)
+	if loc == nil {
+		return "", false
+	}
+	method := index.Definition[loc[2]:loc[3]]
+	keys, ok := splitKeys(index.Definition[loc[1]:])
+	if !ok {
+		return "", false
+	}
+
+	for i, key := range keys {
+		if !plainIdent.MatchString(key) && !quotedIdent.MatchString(key) {
+			keys[i] = "`" + key + "`"
+		}
+	}
+
+	settings := []string{"name: " + dbmlString(index.Name)}
+	if index.Unique {
+		settings = append(settings, "unique")
+	}
+	if method == "btree" || method == "hash" {
+		settings = append(settings, "type: "+method)
+	}
+	if index.partial() {
+		settings = append(settings, "note: "+dbmlString("partial index: "+index.Definition))
+	}
+	return fmt.Sprintf("(%s) [%s]", strings.Join(keys, ", "), strings.Join(settings, ", ")), true
+}
+
+var quotedIdent = regexp.MustCompile(`^"(?:[^"]|"")+"$`)
+
+// splitKeys splits the index keys at the start of s, up to the parenthesis
+// closing the list, at top-level commas.
+func splitKeys(s string) ([]string, bool) {
+	var keys []string
+	depth, start := 0, 0
+	inQuote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuote != 0:
+			if c == inQuote {
+				inQuote = 0
+			}
+		case c == '\'' || c == '"':
+			inQuote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')' || (c == ',' && depth == 0):
+			keys = append(keys, strings.TrimSpace(s[start:i]))
+			start = i + 1
+			if c == ')' {
+				return keys, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func dbmlName(schema, name string) string {
+	return dbmlIdent(schema) + "." + dbmlIdent(name)
+}
+
+func dbmlColumns(schema, table string, columns []string) string {
+	if len(columns) == 1 {
+		return dbmlName(schema, table) + "." + dbmlIdent(columns[0])
+	}
+	return dbmlName(schema, table) + "." + dbmlColumnList(columns)
+}
+
+func dbmlColumnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = dbmlIdent(c)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+var dbmlPlain = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func dbmlIdent(name string) string {
+	if dbmlPlain.MatchString(name) {
+		return name
+	}
+	return dbmlQuote(name)
+}
+
+func dbmlQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// dbmlType quotes types that DBML can't take as written, such as those
+// with spaces.
+func dbmlType(t string) string {
+	if strings.ContainsAny(t, ` "`) {
+		return dbmlQuote(t)
+	}
+	return t
+}
+
+// dbmlString writes s as a DBML string, using a multi-line string when s
+// spans several lines.
+func dbmlString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	if strings.Contains(s, "\n") {
+		return "'''" + strings.ReplaceAll(s, "'''", `\'''`) + "'''"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
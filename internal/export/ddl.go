@@ -0,0 +1,257 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DDL renders a document as SQL that recreates the selected objects in an
+// empty database: schemas, enum types, sequences used by defaults, tables
+// in dependency order, indexes and comments.
+type DDL struct{}
+
+func (DDL) Name() string      { return "ddl" }
+func (DDL) Extension() string { return "sql" }
+
+// nextvalPattern finds the sequence of serial-style column defaults.
+var nextvalPattern = regexp.MustCompile(`nextval\('((?:[^']|'')+)'::regclass\)`)
+
+func (DDL) Export(w io.Writer, doc *Document) error {
+	var b strings.Builder
+
+	if lines := doc.HeaderLines(); len(lines) > 0 {
+		for _, line := range lines {
+			b.WriteString(strings.TrimRight("-- "+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	// Schemas, including those that only hold enum types
+	var schemas []string
+	for _, schema := range doc.Schemas {
+		schemas = append(schemas, schema.Name)
+	}
+	for _, enum := range doc.Enums {
+		if !slices.Contains(schemas, enum.Schema) {
+			schemas = append(schemas, enum.Schema)
+		}
+	}
+	for _, schema := range schemas {
+		fmt.Fprintf(&b, "CREATE SCHEMA IF NOT EXISTS %s;\n", quoteIdent(schema))
+	}
+	if len(schemas) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, enum := range doc.Enums {
+		labels := make([]string, len(enum.Labels))
+		for i, label := range enum.Labels {
+			labels[i] = quoteLiteral(label)
+		}
+		fmt.Fprintf(&b, "CREATE TYPE %s AS ENUM (%s);\n\n", qualifiedName(enum.Schema, enum.Name), strings.Join(labels, ", "))
+	}
+
+	tables := dependencyOrder(doc)
+
+	var sequences []string
+	for _, t := range tables {
+		for _, col := range t.table.Columns {
+			for _, match := range nextvalPattern.FindAllStringSubmatch(col.Default, -1) {
+				if name := strings.ReplaceAll(match[1], "''", "'"); !slices.Contains(sequences, name) {
+					sequences = append(sequences, name)
+				}
+			}
+		}
+	}
+	for _, sequence := range sequences {
+		fmt.Fprintf(&b, "CREATE SEQUENCE IF NOT EXISTS %s;\n", sequence)
+	}
+	if len(sequences) > 0 {
+		b.WriteString("\n")
+	}
+
+	// Foreign keys to tables created later are added once all tables exist
+	created := make(map[[2]string]bool)
+	var deferred []string
+	for _, t := range tables {
+		name := qualifiedName(t.schema, t.table.Name)
+		var lines []string
+		for _, col := range t.table.Columns {
+			lines = append(lines, "    "+columnDefinition(col))
+		}
+		for _, con := range t.table.Constraints {
+			line := con.Definition
+			if con.Name != "" {
+				line = fmt.Sprintf("CONSTRAINT %s %s", quoteIdent(con.Name), con.Definition)
+			}
+			if ref := con.References; ref != nil {
+				target := [2]string{ref.Schema, ref.Table}
+				switch {
+				case !doc.hasColumns(ref):
+					fmt.Fprintf(&b, "-- %s: foreign key (%s) references %s, which is not exported\n",
+						name, strings.Join(con.Columns, ", "), qualifiedName(ref.Schema, ref.Table))
+					continue
+				case !created[target] && target != [2]string{t.schema, t.table.Name}:
+					deferred = append(deferred, fmt.Sprintf("ALTER TABLE %s ADD %s;\n", name, line))
+					continue
+				}
+			}
+			lines = append(lines, "    "+line)
+		}
+
+		fmt.Fprintf(&b, "CREATE TABLE %s (\n%s\n);\n\n", name, strings.Join(lines, ",\n"))
+		created[[2]string{t.schema, t.table.Name}] = true
+	}
+
+	for _, statement := range deferred {
+		b.WriteString(statement)
+	}
+	if len(deferred) > 0 {
+		b.WriteString("\n")
+	}
+
+	var indexes int
+	for _, t := range tables {
+		for _, index := range t.table.Indexes {
+			b.WriteString(index.Definition + ";\n")
+			indexes++
+		}
+	}
+	if indexes > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, t := range tables {
+		name := qualifiedName(t.schema, t.table.Name)
+		if t.table.Description != "" {
+			fmt.Fprintf(&b, "COMMENT ON TABLE %s IS %s;\n", name, quoteLiteral(t.table.Description))
+		}
+		for _, col := range t.table.Columns {
+			if col.Description != "" {
+				fmt.Fprintf(&b, "COMMENT ON COLUMN %s.%s IS %s;\n", name, quoteIdent(col.Name), quoteLiteral(col.Description))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n")
+	return err
+}
+
+func columnDefinition(col Column) string {
+	parts := []string{quoteIdent(col.Name), col.Type}
+	switch {
+	case col.Identity != "":
+		parts = append(parts, identityClause(col.Identity))
+	case col.Generated != "":
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", col.Generated))
+	case col.Default != "":
+		parts = append(parts, "DEFAULT "+col.Default)
+	}
+	if !col.Nullable {
+		parts = append(parts, "NOT NULL")
+	}
+	return strings.Join(parts, " ")
+}
+
+func identityClause(identity string) string {
+	if identity == "by_default" {
+		return "GENERATED BY DEFAULT AS IDENTITY"
+	}
+	return "GENERATED ALWAYS AS IDENTITY"
+}
+
+type schemaTable struct {
+	schema string
+	table  *Table
+}
+
+// dependencyOrder returns the tables of doc with tables referenced by
+// foreign keys before those referencing them, keeping the document order
+// otherwise. Tables in a reference cycle keep their relative order.
+func dependencyOrder(doc *Document) []schemaTable {
+	tables := make(map[[2]string]*Table)
+	var keys [][2]string
+	for i := range doc.Schemas {
+		for j := range doc.Schemas[i].Tables {
+			key := [2]string{doc.Schemas[i].Name, doc.Schemas[i].Tables[j].Name}
+			tables[key] = &doc.Schemas[i].Tables[j]
+			keys = append(keys, key)
+		}
+	}
+
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[[2]string]int)
+	var ordered []schemaTable
+
+	var visit func(key [2]string)
+	visit = func(key [2]string) {
+		if state[key] != 0 {
+			return
+		}
+		state[key] = visiting
+		for _, con := range tables[key].Constraints {
+			if ref := con.References; ref != nil {
+				if target := [2]string{ref.Schema, ref.Table}; tables[target] != nil {
+					visit(target)
+				}
+			}
+		}
+		state[key] = done
+		ordered = append(ordered, schemaTable{schema: key[0], table: tables[key]})
+	}
+	for _, key := range keys {
+		visit(key)
+	}
+	return ordered
+}
+
+func qualifiedName(schema, name string) string {
+	return quoteIdent(schema) + "." + quoteIdent(name)
+}
+
+var plainIdent = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+
+// reservedWords are the PostgreSQL keywords that can't be used as
+// identifiers without quoting.
+var reservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true,
+	"array": true, "as": true, "asc": true, "asymmetric": true, "authorization": true,
+	"binary": true, "both": true, "case": true, "cast": true, "check": true,
+	"collate": true, "collation": true, "column": true, "concurrently": true, "constraint": true,
+	"create": true, "cross": true, "current_catalog": true, "current_date": true, "current_role": true,
+	"current_schema": true, "current_time": true, "current_timestamp": true, "current_user": true, "default": true,
+	"deferrable": true, "desc": true, "distinct": true, "do": true, "else": true,
+	"end": true, "except": true, "false": true, "fetch": true, "for": true,
+	"foreign": true, "freeze": true, "from": true, "full": true, "grant": true,
+	"group": true, "having": true, "ilike": true, "in": true, "initially": true,
+	"inner": true, "intersect": true, "into": true, "is": true, "isnull": true,
+	"join": true, "lateral": true, "leading": true, "left": true, "like": true,
+	"limit": true, "localtime": true, "localtimestamp": true, "natural": true, "not": true,
+	"notnull": true, "null": true, "offset": true, "on": true, "only": true,
+	"or": true, "order": true, "outer": true, "overlaps": true, "placing": true,
+	"primary": true, "references": true, "returning": true, "right": true, "select": true,
+	"session_user": true, "similar": true, "some": true, "symmetric": true, "system_user": true,
+	"table": true, "tablesample": true, "then": true, "to": true, "trailing": true,
+	"true": true, "union": true, "unique": true, "user": true, "using": true,
+	"variadic": true, "verbose": true, "when": true, "where": true, "window": true,
+	"with": true,
+}
+
+// quoteIdent quotes name as an SQL identifier when it needs quoting.
+func quoteIdent(name string) string {
+	if plainIdent.MatchString(name) && !reservedWords[name] {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes s as an SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,341 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/kerem-kaynak/llmshark/internal/postgres"
+)
+
+// Version is the version of the document layout written by the JSON and
+// YAML exporters. It changes whenever a field is removed or changes
+// meaning; new fields may be added within a version.
+const Version = 1
+
+// Document is the selected part of the catalog, as handed to every
+// exporter. Its JSON and YAML encodings are the documented interchange
+// format.
+type Document struct {
+	Version int `json:"version" yaml:"version"`
+	// GeneratedAt is set by the timestamp header.
+	GeneratedAt *time.Time `json:"generated_at,omitempty" yaml:"generated_at,omitempty"`
+	// Header is the text of the fixed header.
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// Source is set by the fingerprint header.
+	Source  *Source  `json:"source,omitempty" yaml:"source,omitempty"`
+	Schemas []Schema `json:"schemas" yaml:"schemas"`
+	// Enums are the enum types used by the selected columns.
+	Enums []Enum `json:"enums,omitempty" yaml:"enums,omitempty"`
+}
+
+// Source identifies where a document came from and what it contains.
+type Source struct {
+	Database      string `json:"database,omitempty" yaml:"database,omitempty"`
+	ServerVersion string `json:"server_version,omitempty" yaml:"server_version,omitempty"`
+	// Fingerprint is a hash of the schemas and enums, which changes only
+	// when they do.
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+}
+
+type Schema struct {
+	Name   string  `json:"name" yaml:"name"`
+	Tables []Table `json:"tables" yaml:"tables"`
+}
+
+type Table struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Columns     []Column `json:"columns" yaml:"columns"`
+	// Constraints and indexes are left out when they use columns that
+	// aren't selected.
+	Constraints []Constraint `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+	Indexes     []Index      `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+}
+
+type Column struct {
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type" yaml:"type"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Nullable    bool   `json:"nullable" yaml:"nullable"`
+	// Default is the default expression, omitted when there is none.
+	Default    string `json:"default,omitempty" yaml:"default,omitempty"`
+	PrimaryKey bool   `json:"primary_key" yaml:"primary_key"`
+	Unique     bool   `json:"unique" yaml:"unique"`
+	// Constraints names every constraint the column takes part in.
+	Constraints []string `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+	// Identity is "always" or "by_default" for identity columns.
+	Identity string `json:"identity,omitempty" yaml:"identity,omitempty"`
+	// Generated is the expression of a stored generated column.
+	Generated string `json:"generated,omitempty" yaml:"generated,omitempty"`
+}
+
+// Constraint types.
+const (
+	PrimaryKey = "primary_key"
+	Unique     = "unique"
+	ForeignKey = "foreign_key"
+	Check      = "check"
+	Exclusion  = "exclusion"
+)
+
+type Constraint struct {
+	Name    string   `json:"name" yaml:"name"`
+	Type    string   `json:"type" yaml:"type"`
+	Columns []string `json:"columns" yaml:"columns"`
+	// Definition is the constraint as written after CONSTRAINT name.
+	Definition string `json:"definition" yaml:"definition"`
+	// References is the table a foreign key points to.
+	References *Reference `json:"references,omitempty" yaml:"references,omitempty"`
+}
+
+type Reference struct {
+	Schema  string   `json:"schema" yaml:"schema"`
+	Table   string   `json:"table" yaml:"table"`
+	Columns []string `json:"columns" yaml:"columns"`
+}
+
+// Index is an index other than those backing primary key, unique and
+// exclusion constraints.
+type Index struct {
+	Name string `json:"name" yaml:"name"`
+	// Columns are all columns the index uses, including in expressions and
+	// its predicate.
+	Columns []string `json:"columns" yaml:"columns"`
+	Unique  bool     `json:"unique" yaml:"unique"`
+	// Definition is the complete CREATE INDEX statement.
+	Definition string `json:"definition" yaml:"definition"`
+}
+
+// partial reports whether the index has a predicate.
+func (i Index) partial() bool {
+	return strings.Contains(i.Definition, " WHERE ")
+}
+
+type Enum struct {
+	Schema string   `json:"schema" yaml:"schema"`
+	Name   string   `json:"name" yaml:"name"`
+	Labels []string `json:"labels" yaml:"labels"`
+}
+
+var constraintTypes = map[string]string{
+	postgres.ConstraintPrimaryKey: PrimaryKey,
+	postgres.ConstraintUnique:     Unique,
+	postgres.ConstraintForeignKey: ForeignKey,
+	postgres.ConstraintCheck:      Check,
+	postgres.ConstraintExclusion:  Exclusion,
+}
+
+var identityKinds = map[string]string{
+	postgres.IdentityAlways:    "always",
+	postgres.IdentityByDefault: "by_default",
+}
+
+// Header modes, deciding what identifies a document below its title.
+const (
+	// HeaderTimestamp gives the generation time.
+	HeaderTimestamp = "timestamp"
+	// HeaderFingerprint gives the database, server version and a hash of
+	// the contents, so regenerating an unchanged schema gives the same
+	// output.
+	HeaderFingerprint = "fingerprint"
+	// HeaderFixed gives the text in Options.HeaderText.
+	HeaderFixed = "fixed"
+	HeaderNone  = "none"
+)
+
+// HeaderModes lists the header modes.
+var HeaderModes = []string{HeaderTimestamp, HeaderFingerprint, HeaderFixed, HeaderNone}
+
+// Options control what goes into a document besides the selected objects.
+type Options struct {
+	// Header is one of HeaderModes, with an empty mode meaning none.
+	Header     string
+	HeaderText string
+	// Database and ServerVersion identify the source in the fingerprint
+	// header.
+	Database      string
+	ServerVersion string
+
+	Descriptions bool
+
+	// Redact, when set, is applied to descriptions, column defaults and
+	// generated expressions, and constraint and index definitions, which
+	// can hold literals such as CHECK values and partial index predicates.
+	Redact func(string) string
+}
+
+// NewDocument builds the document for the selected objects in schemas.
+func NewDocument(schemas []postgres.Schema, opts Options) *Document {
+	redact := opts.Redact
+	if redact == nil {
+		redact = func(s string) string { return s }
+	}
+	describe := func(s string) string {
+		if !opts.Descriptions {
+			return ""
+		}
+		return redact(s)
+	}
+
+	doc := &Document{Version: Version, Schemas: []Schema{}}
+
+	seenEnums := make(map[*postgres.Enum]bool)
+
+	for _, s := range Select(schemas) {
+		schema := Schema{Name: s.Name, Tables: make([]Table, 0, len(s.Tables))}
+		for _, t := range s.Tables {
+			table := Table{
+				Name:        t.Name,
+				Description: describe(t.Description),
+				Columns:     make([]Column, 0, len(t.Columns)),
+			}
+			selected := make(map[string]bool, len(t.Columns))
+			for _, c := range t.Columns {
+				selected[c.Name] = true
+
+				column := Column{
+					Name:        c.Name,
+					Type:        c.Type,
+					Description: describe(c.Description),
+					Nullable:    c.IsNullable,
+					PrimaryKey:  c.IsPrimary,
+					Unique:      c.IsUnique,
+					Constraints: c.Constraints,
+					Identity:    identityKinds[c.Identity],
+				}
+				switch {
+				case c.Generated:
+					column.Generated = redact(c.Default)
+				case c.HasDefault:
+					column.Default = redact(c.Default)
+				}
+				table.Columns = append(table.Columns, column)
+
+				if c.Enum != nil && !seenEnums[c.Enum] {
+					seenEnums[c.Enum] = true
+					doc.Enums = append(doc.Enums, Enum{Schema: c.Enum.Schema, Name: c.Enum.Name, Labels: c.Enum.Labels})
+				}
+			}
+
+			for _, con := range t.Constraints {
+				if !allSelected(con.Columns, selected) {
+					continue
+				}
+				constraint := Constraint{
+					Name:       con.Name,
+					Type:       constraintTypes[con.Type],
+					Columns:    con.Columns,
+					Definition: redact(con.Definition),
+				}
+				if con.Type == postgres.ConstraintForeignKey {
+					constraint.References = &Reference{Schema: con.RefSchema, Table: con.RefTable, Columns: con.RefColumns}
+				}
+				table.Constraints = append(table.Constraints, constraint)
+			}
+			for _, index := range t.Indexes {
+				if allSelected(index.Columns, selected) {
+					index := Index(index)
+					index.Definition = redact(index.Definition)
+					table.Indexes = append(table.Indexes, index)
+				}
+			}
+			schema.Tables = append(schema.Tables, table)
+		}
+		doc.Schemas = append(doc.Schemas, schema)
+	}
+
+	switch opts.Header {
+	case HeaderTimestamp:
+		now := time.Now()
+		doc.GeneratedAt = &now
+	case HeaderFixed:
+		doc.Header = opts.HeaderText
+	case HeaderFingerprint:
+		doc.Source = &Source{
+			Database:      opts.Database,
+			ServerVersion: opts.ServerVersion,
+			Fingerprint:   doc.fingerprint(),
+		}
+	}
+	return doc
+}
+
+// fingerprint returns a hash of the schemas and enums of the document.
+func (doc *Document) fingerprint() string {
+	// Encoding plain structs can't fail
+	data, _ := json.Marshal(struct {
+		Schemas []Schema
+		Enums   []Enum
+	}{doc.Schemas, doc.Enums})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// HeaderLines returns the lines identifying the document below its title,
+// in the order the header modes set them.
+func (doc *Document) HeaderLines() []string {
+	var lines []string
+	if doc.GeneratedAt != nil {
+		lines = append(lines, "Generated: "+doc.GeneratedAt.Format("2006-01-02 15:04:05"))
+	}
+	if doc.Header != "" {
+		lines = append(lines, strings.Split(strings.TrimRight(doc.Header, "\n"), "\n")...)
+	}
+	if src := doc.Source; src != nil {
+		var parts []string
+		if src.Database != "" {
+			parts = append(parts, "Database: "+src.Database)
+		}
+		if src.ServerVersion != "" {
+			parts = append(parts, "PostgreSQL "+src.ServerVersion)
+		}
+		parts = append(parts, "Fingerprint: "+src.Fingerprint)
+		lines = append(lines, strings.Join(parts, ", "))
+	}
+	return lines
+}
+
+func allSelected(columns []string, selected map[string]bool) bool {
+	for _, column := range columns {
+		if !selected[column] {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the selected part of schemas. A selected schema or table
+// brings in everything below it, and a selected column brings in its table
+// and schema with just the selected columns.
+func Select(schemas []postgres.Schema) []postgres.Schema {
+	var selected []postgres.Schema
+	for _, schema := range schemas {
+		var tables []postgres.Table
+		for _, table := range schema.Tables {
+			whole := schema.Selected || table.Selected
+
+			var columns []postgres.Column
+			for _, col := range table.Columns {
+				if whole || col.Selected {
+					columns = append(columns, col)
+				}
+			}
+			if !whole && len(columns) == 0 {
+				continue
+			}
+
+			table.Columns = columns
+			tables = append(tables, table)
+		}
+		if !schema.Selected && len(tables) == 0 {
+			continue
+		}
+
+		schema.Tables = tables
+		selected = append(selected, schema)
+	}
+	return selected
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kerem-kaynak/llmshark/internal/postgres"
+)
+
+func TestNewDocumentRedactsDefinitions(t *testing.T) {
+	email := regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.]+`)
+	schemas := []postgres.Schema{{
+		Name:     "shop",
+		Selected: true,
+		Tables: []postgres.Table{{
+			Name:        "users",
+			Description: "Owner: admin@example.com",
+			Columns: []postgres.Column{
+				{Name: "email", Type: "text", HasDefault: true, Default: "'nobody@example.com'::text"},
+				{Name: "contact", Type: "text", Generated: true, Default: "COALESCE(email, 'ops@example.com')"},
+			},
+			Constraints: []postgres.Constraint{
+				{Name: "users_email_check", Type: postgres.ConstraintCheck, Columns: []string{"email"},
+					Definition: "CHECK (email <> 'root@example.com')"},
+			},
+			Indexes: []postgres.Index{
+				{Name: "users_staff_idx", Columns: []string{"email"},
+					Definition: "CREATE INDEX users_staff_idx ON shop.users USING btree (email) WHERE (email = 'boss@staff.example.com')"},
+			},
+		}},
+	}}
+
+	doc := NewDocument(schemas, Options{
+		Descriptions: true,
+		Redact:       func(s string) string { return email.ReplaceAllString(s, "[email]") },
+	})
+
+	table := doc.Schemas[0].Tables[0]
+	for what, s := range map[string]string{
+		"description":      table.Description,
+		"default":          table.Columns[0].Default,
+		"generated":        table.Columns[1].Generated,
+		"constraint":       table.Constraints[0].Definition,
+		"index definition": table.Indexes[0].Definition,
+	} {
+		if email.MatchString(s) || !strings.Contains(s, "[email]") {
+			t.Errorf("%s was not redacted: %q", what, s)
+		}
+	}
+}
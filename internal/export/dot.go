@@ -0,0 +1,98 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DOT renders a document as a Graphviz digraph with a record-shaped node
+// per table and an edge per foreign key.
+type DOT struct{}
+
+func (DOT) Name() string      { return "dot" }
+func (DOT) Extension() string { return "dot" }
+
+// dotRecord escapes the characters that structure record labels.
+var dotRecord = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"{", `\{`,
+	"}", `\}`,
+	"|", `\|`,
+	"<", `\<`,
+	">", `\>`,
+	"\n", " ",
+)
+
+func (DOT) Export(w io.Writer, doc *Document) error {
+	var b strings.Builder
+
+	b.WriteString("digraph schema {\n")
+	b.WriteString("    graph [rankdir=LR];\n")
+	b.WriteString("    node [shape=record, fontname=\"Helvetica\"];\n")
+	b.WriteString("    edge [fontname=\"Helvetica\", fontsize=10];\n\n")
+
+	for _, schema := range doc.Schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+
+			// Ports are numbered so any column name can be linked to
+			fields := []string{dotRecord.Replace(schema.Name + "." + table.Name)}
+			for j, col := range table.Columns {
+				field := col.Name + " : " + col.Type
+				if kinds := table.keyKinds(col.Name); len(kinds) > 0 {
+					field += " (" + strings.Join(kinds, ", ") + ")"
+				}
+				fields = append(fields, fmt.Sprintf("<c%d> %s\\l", j, dotRecord.Replace(field)))
+			}
+
+			fmt.Fprintf(&b, "    %s [label=\"{%s}\"", dotID(schema.Name, table.Name), strings.Join(fields, "|"))
+			if table.Description != "" {
+				fmt.Fprintf(&b, ", tooltip=%s", dotString(table.Description))
+			}
+			b.WriteString("];\n")
+		}
+	}
+
+	var edges bool
+	for _, schema := range doc.Schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			for _, fk := range doc.foreignKeys(table) {
+				ref := fk.References
+				parent := doc.findTable(ref.Schema, ref.Table)
+				if !edges {
+					b.WriteString("\n")
+					edges = true
+				}
+				fmt.Fprintf(&b, "    %s:c%d -> %s:c%d [label=%s];\n",
+					dotID(schema.Name, table.Name), columnIndex(table, fk.Columns[0]),
+					dotID(ref.Schema, ref.Table), columnIndex(parent, ref.Columns[0]),
+					dotString(fk.Name))
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotID(schema, table string) string {
+	return dotString(schema + "." + table)
+}
+
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func columnIndex(t *Table, name string) int {
+	for i, c := range t.Columns {
+		if c.Name == name {
+			return i
+		}
+	}
+	return 0
+}
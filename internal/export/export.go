@@ -0,0 +1,63 @@
+// Package export renders the selected part of a database catalog in the
+// formats LLMShark can copy or write out.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Exporter renders a document in one output format.
+type Exporter interface {
+	// Name identifies the format on the command line and in the TUI.
+	Name() string
+	// Extension is the file extension used when saving, without the dot.
+	Extension() string
+	Export(w io.Writer, doc *Document) error
+}
+
+// exporters lists the available formats, the first being the default.
+var exporters = []Exporter{
+	Markdown{},
+	Compact{},
+	JSON{},
+	YAML{},
+	DDL{},
+	Mermaid{},
+	DBML{},
+	DOT{},
+}
+
+// Formats returns the names of the available formats.
+func Formats() []string {
+	names := make([]string, len(exporters))
+	for i, e := range exporters {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+// DefaultFormat is used when no format is configured.
+func DefaultFormat() string {
+	return exporters[0].Name()
+}
+
+// Lookup returns the exporter for the named format.
+func Lookup(name string) (Exporter, error) {
+	for _, e := range exporters {
+		if e.Name() == name {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown export format %q (expected one of %s)", name, strings.Join(Formats(), ", "))
+}
+
+// String renders doc with e.
+func String(e Exporter, doc *Document) (string, error) {
+	var b strings.Builder
+	if err := e.Export(&b, doc); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,35 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSON writes the document in its versioned interchange layout.
+type JSON struct{}
+
+func (JSON) Name() string      { return "json" }
+func (JSON) Extension() string { return "json" }
+
+func (JSON) Export(w io.Writer, doc *Document) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// YAML writes the same layout as JSON, in YAML.
+type YAML struct{}
+
+func (YAML) Name() string      { return "yaml" }
+func (YAML) Extension() string { return "yaml" }
+
+func (YAML) Export(w io.Writer, doc *Document) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -1,62 +1,31 @@
-package markdown
+package export
 
 import (
 	"fmt"
+	"io"
 	"strings"
-	"time"
-
-	"github.com/kerem-kaynak/llmshark/internal/postgres"
 )
 
-func Generate(schemas []postgres.Schema) string {
-	var b strings.Builder
+// Markdown renders a document as headings per schema and table with a
+// column table each.
+type Markdown struct{}
 
-	b.WriteString("# Database Schema Documentation\n\n")
-	b.WriteString(fmt.Sprintf("Generated: %s\n\n", time.Now().Format("2006-01-02 15:04:05")))
+func (Markdown) Name() string      { return "markdown" }
+func (Markdown) Extension() string { return "md" }
 
-	// Iterate through schemas
-	for _, schema := range schemas {
-		// Check if the schema or any of its tables/columns are selected
-		schemaHasSelection := schema.Selected
-		for _, table := range schema.Tables {
-			if table.Selected {
-				schemaHasSelection = true
-				break
-			}
-			for _, col := range table.Columns {
-				if col.Selected {
-					schemaHasSelection = true
-					break
-				}
-			}
-		}
+func (Markdown) Export(w io.Writer, doc *Document) error {
+	var b strings.Builder
 
-		// Skip schema if nothing is selected
-		if !schemaHasSelection {
-			continue
-		}
+	b.WriteString("# Database Schema Documentation\n\n")
+	if lines := doc.HeaderLines(); len(lines) > 0 {
+		b.WriteString(strings.Join(lines, "\n") + "\n\n")
+	}
 
+	for _, schema := range doc.Schemas {
 		// Add schema header
 		b.WriteString(fmt.Sprintf("## Schema: `%s`\n\n", schema.Name))
 
-		// Iterate through tables
 		for _, table := range schema.Tables {
-			// Check if the table or any of its columns are selected
-			tableHasSelection := table.Selected || schema.Selected
-			if !tableHasSelection {
-				for _, col := range table.Columns {
-					if col.Selected {
-						tableHasSelection = true
-						break
-					}
-				}
-			}
-
-			// Skip table if nothing is selected
-			if !tableHasSelection {
-				continue
-			}
-
 			// Add table header
 			b.WriteString(fmt.Sprintf("### Table: `%s`\n\n", table.Name))
 			if table.Description != "" {
@@ -68,27 +37,27 @@ func Generate(schemas []postgres.Schema) string {
 			b.WriteString("| Name | Type | Constraints | Description |\n")
 			b.WriteString("|------|------|-------------|-------------|\n")
 
-			// Iterate through columns
 			for _, col := range table.Columns {
-				// Include column if it's selected, or if the table/schema is selected
-				if !col.Selected && !table.Selected && !schema.Selected {
-					continue
-				}
-
 				// Build constraints
 				constraints := make([]string, 0)
-				if col.IsPrimary {
+				if col.PrimaryKey {
 					constraints = append(constraints, "PRIMARY KEY")
 				}
-				if col.IsUnique {
+				if col.Unique {
 					constraints = append(constraints, "UNIQUE")
 				}
-				if !col.IsNullable {
+				if !col.Nullable {
 					constraints = append(constraints, "NOT NULL")
 				}
-				if col.HasDefault {
+				if col.Default != "" {
 					constraints = append(constraints, fmt.Sprintf("DEFAULT %s", col.Default))
 				}
+				if col.Generated != "" {
+					constraints = append(constraints, fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", col.Generated))
+				}
+				if col.Identity != "" {
+					constraints = append(constraints, identityClause(col.Identity))
+				}
 				if len(col.Constraints) > 0 {
 					constraints = append(constraints, col.Constraints...)
 				}
@@ -114,5 +83,6 @@ func Generate(schemas []postgres.Schema) string {
 		}
 	}
 
-	return b.String()
+	_, err := io.WriteString(w, b.String())
+	return err
 }
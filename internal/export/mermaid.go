@@ -0,0 +1,132 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Mermaid renders a document as a Mermaid erDiagram, with an entity per
+// table and a relationship per foreign key.
+type Mermaid struct{}
+
+func (Mermaid) Name() string      { return "mermaid" }
+func (Mermaid) Extension() string { return "mmd" }
+
+// mermaidUnsafe matches characters Mermaid doesn't accept in attribute
+// types and names, and mermaidUnsafeID those it doesn't accept in entity ids.
+var (
+	mermaidUnsafe   = regexp.MustCompile(`[^A-Za-z0-9_\-()\[\]]`)
+	mermaidUnsafeID = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// mermaidComment makes names and descriptions fit in a quoted label or
+// comment, which has no way to escape a double quote.
+var mermaidComment = strings.NewReplacer(`"`, "'", "\r", "", "\n", " ")
+
+func (Mermaid) Export(w io.Writer, doc *Document) error {
+	var b strings.Builder
+
+	b.WriteString("erDiagram\n")
+
+	ids := mermaidIDs(doc)
+	for _, schema := range doc.Schemas {
+		for _, table := range schema.Tables {
+			fmt.Fprintf(&b, "    %s[\"%s\"] {\n", ids[[2]string{schema.Name, table.Name}],
+				mermaidComment.Replace(schema.Name+"."+table.Name))
+			for _, col := range table.Columns {
+				line := mermaidName(col.Type) + " " + mermaidName(col.Name)
+				if kinds := table.keyKinds(col.Name); len(kinds) > 0 {
+					line += " " + strings.Join(kinds, ", ")
+				}
+				if col.Description != "" {
+					line += ` "` + mermaidComment.Replace(col.Description) + `"`
+				}
+				b.WriteString("        " + line + "\n")
+			}
+			b.WriteString("    }\n")
+		}
+	}
+
+	for _, schema := range doc.Schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			for _, fk := range doc.foreignKeys(table) {
+				ref := fk.References
+				parent := doc.findTable(ref.Schema, ref.Table)
+
+				// A child row refers to at most one parent, or none when the
+				// key is nullable; unique keys allow one child per parent
+				parentSide := "||"
+				if table.nullable(fk.Columns) {
+					parentSide = "|o"
+				}
+				childSide := "o{"
+				if table.isUnique(fk.Columns) {
+					childSide = "o|"
+				}
+				// Keys that are part of the child's primary key identify it
+				line := "--"
+				if !identifying(table, fk.Columns) {
+					line = ".."
+				}
+
+				fmt.Fprintf(&b, "    %s %s%s%s %s : \"%s\"\n",
+					ids[[2]string{ref.Schema, parent.Name}], parentSide, line, childSide,
+					ids[[2]string{schema.Name, table.Name}], mermaidComment.Replace(fk.Name))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidIDs returns an entity id for every table of doc, keyed by schema
+// and table name. Names that differ only in replaced characters get a
+// numeric suffix to keep them apart.
+func mermaidIDs(doc *Document) map[[2]string]string {
+	ids := make(map[[2]string]string)
+	taken := make(map[string]bool)
+	for _, schema := range doc.Schemas {
+		for _, table := range schema.Tables {
+			base := mermaidName(mermaidUnsafeID.ReplaceAllString(schema.Name+"__"+table.Name, "_"))
+			id := base
+			for n := 2; taken[id]; n++ {
+				id = fmt.Sprintf("%s_%d", base, n)
+			}
+			taken[id] = true
+			ids[[2]string{schema.Name, table.Name}] = id
+		}
+	}
+	return ids
+}
+
+// mermaidName replaces the characters Mermaid doesn't accept in a name,
+// which also has to start with a letter or an underscore.
+func mermaidName(name string) string {
+	name = mermaidUnsafe.ReplaceAllString(name, "_")
+	if name == "" || !(name[0] == '_' || 'A' <= name[0] && name[0] <= 'Z' || 'a' <= name[0] && name[0] <= 'z') {
+		name = "_" + name
+	}
+	return name
+}
+
+// identifying reports whether all columns are part of the primary key of t.
+func identifying(t *Table, columns []string) bool {
+	for _, con := range t.Constraints {
+		if con.Type == PrimaryKey {
+			return allSelected(columns, toSet(con.Columns))
+		}
+	}
+	return false
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMermaidQuotesNames(t *testing.T) {
+	doc := &Document{Schemas: []Schema{{
+		Name: "My Shop",
+		Tables: []Table{
+			{
+				Name: `order "items"`,
+				Columns: []Column{
+					{Name: "id", Type: "integer"},
+					{Name: "2nd line", Type: "character varying(20)"},
+					{Name: "order id", Type: "integer"},
+				},
+				Constraints: []Constraint{{
+					Name: `fk "order"`, Type: ForeignKey, Columns: []string{"order id"},
+					References: &Reference{Schema: "My Shop", Table: "order.list", Columns: []string{"id"}},
+				}},
+			},
+			{Name: "order.list", Columns: []Column{{Name: "id", Type: "integer"}}},
+			{Name: "order_list", Columns: []Column{{Name: "id", Type: "integer"}}},
+		},
+	}}}
+
+	var b strings.Builder
+	if err := (Mermaid{}).Export(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	entity := regexp.MustCompile(`^    ([A-Za-z_][A-Za-z0-9_]*)\["([^"]*)"\] \{$`)
+	attribute := regexp.MustCompile(`^        [A-Za-z_][A-Za-z0-9_\-()\[\]]* [A-Za-z_][A-Za-z0-9_\-()\[\]]*( [A-Z, ]+)?$`)
+	relationship := regexp.MustCompile(`^    ([A-Za-z_][A-Za-z0-9_]*) \S+ ([A-Za-z_][A-Za-z0-9_]*) : "[^"]*"$`)
+
+	ids := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n")[1:] {
+		switch {
+		case entity.MatchString(line):
+			id := entity.FindStringSubmatch(line)[1]
+			if ids[id] {
+				t.Errorf("entity id %s is used twice:\n%s", id, out)
+			}
+			ids[id] = true
+		case attribute.MatchString(line), line == "    }":
+		case relationship.MatchString(line):
+			for _, id := range relationship.FindStringSubmatch(line)[1:] {
+				if !ids[id] {
+					t.Errorf("relationship refers to unknown entity %s:\n%s", id, out)
+				}
+			}
+		default:
+			t.Errorf("invalid line %q in:\n%s", line, out)
+		}
+	}
+	if len(ids) != 3 {
+		t.Errorf("got %d entities, want 3:\n%s", len(ids), out)
+	}
+	if !strings.Contains(out, `["My Shop.order 'items'"]`) {
+		t.Errorf("table label is missing:\n%s", out)
+	}
+}
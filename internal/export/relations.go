@@ -0,0 +1,104 @@
+package export
+
+import "slices"
+
+// findTable returns the table of doc with the given schema and name, or
+// nil if it isn't part of the document.
+func (doc *Document) findTable(schema, name string) *Table {
+	for i := range doc.Schemas {
+		if doc.Schemas[i].Name != schema {
+			continue
+		}
+		for j := range doc.Schemas[i].Tables {
+			if doc.Schemas[i].Tables[j].Name == name {
+				return &doc.Schemas[i].Tables[j]
+			}
+		}
+	}
+	return nil
+}
+
+// hasColumns reports whether the table and columns ref points to are part
+// of doc.
+func (doc *Document) hasColumns(ref *Reference) bool {
+	table := doc.findTable(ref.Schema, ref.Table)
+	if table == nil {
+		return false
+	}
+	for _, name := range ref.Columns {
+		if table.column(name) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Table) column(name string) *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// isUnique reports whether a primary key, unique constraint or unique index
+// without a predicate covers exactly columns.
+func (t *Table) isUnique(columns []string) bool {
+	same := func(other []string) bool {
+		return len(other) == len(columns) && !slices.ContainsFunc(other, func(c string) bool {
+			return !slices.Contains(columns, c)
+		})
+	}
+	for _, con := range t.Constraints {
+		if (con.Type == PrimaryKey || con.Type == Unique) && same(con.Columns) {
+			return true
+		}
+	}
+	for _, index := range t.Indexes {
+		if index.Unique && !index.partial() && same(index.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyKinds returns which kinds of key column takes part in: PK, FK and UK.
+func (t *Table) keyKinds(column string) []string {
+	var kinds []string
+	for _, kind := range []struct {
+		name  string
+		types []string
+	}{
+		{"PK", []string{PrimaryKey}},
+		{"FK", []string{ForeignKey}},
+		{"UK", []string{Unique}},
+	} {
+		if slices.ContainsFunc(t.Constraints, func(con Constraint) bool {
+			return slices.Contains(kind.types, con.Type) && slices.Contains(con.Columns, column)
+		}) {
+			kinds = append(kinds, kind.name)
+		}
+	}
+	return kinds
+}
+
+// foreignKeys returns the foreign keys of t whose referenced table and
+// columns are part of doc.
+func (doc *Document) foreignKeys(t *Table) []Constraint {
+	var keys []Constraint
+	for _, con := range t.Constraints {
+		if con.References != nil && doc.hasColumns(con.References) {
+			keys = append(keys, con)
+		}
+	}
+	return keys
+}
+
+// nullable reports whether any of columns of t accepts nulls.
+func (t *Table) nullable(columns []string) bool {
+	return slices.ContainsFunc(columns, func(name string) bool {
+		c := t.column(name)
+		return c != nil && c.Nullable
+	})
+}
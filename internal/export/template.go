@@ -0,0 +1,121 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/kerem-kaynak/llmshark/internal/tokens"
+)
+
+// templateSuffix marks template files in template directories.
+const templateSuffix = ".tmpl"
+
+// Template renders a document with a user-defined text/template. The
+// template is executed with the *Document as its data.
+type Template struct {
+	name      string
+	extension string
+	path      string
+	tmpl      *template.Template
+}
+
+func (t *Template) Name() string      { return t.name }
+func (t *Template) Extension() string { return t.extension }
+
+// Path is the file the template was read from.
+func (t *Template) Path() string { return t.path }
+
+func (t *Template) Export(w io.Writer, doc *Document) error {
+	if err := t.tmpl.Execute(w, doc); err != nil {
+		return fmt.Errorf("template %s: %w", t.name, err)
+	}
+	return nil
+}
+
+// templateFuncs are the helper functions available to templates.
+var templateFuncs = template.FuncMap{
+	"quoteIdent":   quoteIdent,
+	"quoteLiteral": quoteLiteral,
+	"join": func(sep string, items []string) string {
+		return strings.Join(items, sep)
+	},
+	"tokenCount": tokens.Count,
+	"keys": func(t Table, column string) []string {
+		return t.keyKinds(column)
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// ParseTemplate reads a template file. The format is named after the file
+// up to its first dot, and a second extension before .tmpl, as in
+// brief.md.tmpl, is used when saving.
+func ParseTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), templateSuffix)
+	name, extension, _ := strings.Cut(base, ".")
+	if extension == "" {
+		extension = "txt"
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Template{name: name, extension: extension, path: path, tmpl: tmpl}, nil
+}
+
+// LoadTemplates reads the templates at paths, where a directory
+// contributes its *.tmpl files. Template names must not repeat or clash
+// with built-in formats.
+func LoadTemplates(paths []string) ([]*Template, error) {
+	var templates []*Template
+	var errs []error
+	names := make(map[string]string)
+	for _, name := range Formats() {
+		names[name] = "a built-in format"
+	}
+
+	add := func(path string) {
+		t, err := ParseTemplate(path)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		if other, ok := names[t.name]; ok {
+			errs = append(errs, fmt.Errorf("%s: template name %q is already used by %s", path, t.name, other))
+			return
+		}
+		names[t.name] = path
+		templates = append(templates, t)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case info.IsDir():
+			files, err := filepath.Glob(filepath.Join(path, "*"+templateSuffix))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, file := range files {
+				add(file)
+			}
+		default:
+			add(path)
+		}
+	}
+	return templates, errors.Join(errs...)
+}
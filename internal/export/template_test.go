@@ -0,0 +1,105 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func templateDocument() *Document {
+	return &Document{
+		Version: Version,
+		Schemas: []Schema{{
+			Name: "shop",
+			Tables: []Table{{
+				Name:        "order items",
+				Description: "Lines of an order",
+				Columns: []Column{
+					{Name: "id", Type: "integer", PrimaryKey: true},
+					{Name: "order_id", Type: "integer"},
+					{Name: "note", Type: "text", Nullable: true},
+				},
+				Constraints: []Constraint{
+					{Name: "order_items_pkey", Type: PrimaryKey, Columns: []string{"id"}},
+					{Name: "order_items_order_id_fkey", Type: ForeignKey, Columns: []string{"order_id"},
+						References: &Reference{Schema: "shop", Table: "orders", Columns: []string{"id"}}},
+				},
+			}},
+		}},
+	}
+}
+
+func TestTemplateExport(t *testing.T) {
+	// The example from the README, plus the other helpers
+	path := writeTemplate(t, t.TempDir(), "brief.md.tmpl", `
+{{- range .Schemas }}{{ $schema := .Name }}
+{{- range .Tables }}{{ $table := . }}
+## {{ $schema }}.{{ .Name }}{{ with .Description }} — {{ . }}{{ end }}
+{{ range .Columns }}- {{ .Name }} {{ .Type }}{{ with keys $table .Name }} [{{ join ", " . }}]{{ end }}
+{{ end }}{{ quoteIdent .Name }} {{ quoteLiteral "it's" }} {{ upper $schema }} {{ tokenCount "hello world" }}
+{{ end }}{{ end }}`)
+
+	tmpl, err := ParseTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Name() != "brief" || tmpl.Extension() != "md" {
+		t.Errorf("name, extension = %q, %q; want brief, md", tmpl.Name(), tmpl.Extension())
+	}
+
+	got, err := String(tmpl, templateDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `
+## shop.order items — Lines of an order
+- id integer [PK]
+- order_id integer [FK]
+- note text
+"order items" 'it''s' SHOP 2
+`
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTemplateExecutionError(t *testing.T) {
+	path := writeTemplate(t, t.TempDir(), "broken.tmpl", "{{ .NoSuchField }}")
+	tmpl, err := ParseTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := String(tmpl, templateDocument()); err == nil || !strings.Contains(err.Error(), "template broken") {
+		t.Fatalf("executing a broken template = %v, want an error naming it", err)
+	}
+}
+
+func TestLoadTemplatesRejectsClashingNames(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "markdown.tmpl", "{{ len .Schemas }}")
+	writeTemplate(t, dir, "brief.md.tmpl", "{{ len .Schemas }}")
+	other := writeTemplate(t, t.TempDir(), "brief.txt.tmpl", "{{ len .Schemas }}")
+
+	templates, err := LoadTemplates([]string{dir, other})
+	if err == nil {
+		t.Fatal("LoadTemplates accepted a template named after a built-in format")
+	}
+	for _, want := range []string{`"markdown" is already used by a built-in format`, `"brief" is already used by`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't report %s", err, want)
+		}
+	}
+	if len(templates) != 1 || templates[0].Name() != "brief" {
+		t.Errorf("loaded %d templates, want only the first brief", len(templates))
+	}
+}
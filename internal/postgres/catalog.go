@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Identity column kinds, as stored in pg_attribute.attidentity.
+const (
+	IdentityAlways    = "a"
+	IdentityByDefault = "d"
+)
+
+// Constraint types, as stored in pg_constraint.contype.
+const (
+	ConstraintPrimaryKey = "p"
+	ConstraintUnique     = "u"
+	ConstraintForeignKey = "f"
+	ConstraintCheck      = "c"
+	ConstraintExclusion  = "x"
+)
+
+// Constraint is a table constraint.
+type Constraint struct {
+	Name    string
+	Type    string
+	Columns []string
+	// Definition is the constraint as written after CONSTRAINT name.
+	Definition string
+
+	// The referenced table and columns of foreign keys
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+}
+
+// Index is an index not created by a constraint.
+type Index struct {
+	Name string
+	// Columns are all columns the index uses, including in expressions and
+	// its predicate.
+	Columns []string
+	Unique  bool
+	// Definition is the complete CREATE INDEX statement.
+	Definition string
+}
+
+// Enum is a user-defined enum type.
+type Enum struct {
+	Schema string
+	Name   string
+	Labels []string
+}
+
+// enumCache shares one Enum between all columns of the same type.
+type enumCache map[[2]string]*Enum
+
+func (c enumCache) get(schema, name string, labels []string) *Enum {
+	key := [2]string{schema, name}
+	if e, ok := c[key]; ok {
+		return e
+	}
+	e := &Enum{Schema: schema, Name: name, Labels: labels}
+	c[key] = e
+	return e
+}
+
+// tableIndex maps schema and table names to the loaded tables.
+func tableIndex(schemas []Schema) (map[[2]string]*Table, []string, []string) {
+	tables := make(map[[2]string]*Table)
+	var schemaNames, tableNames []string
+	for i := range schemas {
+		for j := range schemas[i].Tables {
+			table := &schemas[i].Tables[j]
+			tables[[2]string{schemas[i].Name, table.Name}] = table
+			schemaNames = append(schemaNames, schemas[i].Name)
+			tableNames = append(tableNames, table.Name)
+		}
+	}
+	return tables, schemaNames, tableNames
+}
+
+// loadConstraints adds the constraints of the loaded tables.
+func loadConstraints(ctx context.Context, tx pgx.Tx, schemas []Schema) error {
+	tables, schemaNames, tableNames := tableIndex(schemas)
+	if len(tables) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, `
+        SELECT
+            n.nspname,
+            c.relname,
+            con.conname,
+            con.contype::text,
+            pg_get_constraintdef(con.oid, true),
+            ARRAY(
+                SELECT a.attname
+                FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+                JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+                ORDER BY k.ord
+            ),
+            COALESCE(fn.nspname, ''),
+            COALESCE(fc.relname, ''),
+            ARRAY(
+                SELECT a.attname
+                FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, ord)
+                JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum
+                ORDER BY k.ord
+            )
+        FROM pg_constraint con
+        JOIN pg_class c ON c.oid = con.conrelid
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        LEFT JOIN pg_class fc ON fc.oid = con.confrelid
+        LEFT JOIN pg_namespace fn ON fn.oid = fc.relnamespace
+        WHERE (n.nspname, c.relname) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+        AND con.contype IN ('p', 'u', 'f', 'c', 'x')
+        ORDER BY n.nspname, c.relname, con.contype, con.conname
+    `, schemaNames, tableNames)
+	if err != nil {
+		return fmt.Errorf("constraint query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName string
+		var con Constraint
+		if err := rows.Scan(
+			&schemaName, &tableName,
+			&con.Name, &con.Type, &con.Definition, &con.Columns,
+			&con.RefSchema, &con.RefTable, &con.RefColumns,
+		); err != nil {
+			return fmt.Errorf("scan failed: %w", err)
+		}
+		table := tables[[2]string{schemaName, tableName}]
+		table.Constraints = append(table.Constraints, con)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("row iteration failed: %w", err)
+	}
+	return nil
+}
+
+// loadIndexes adds the indexes of the loaded tables, except those backing
+// primary key, unique and exclusion constraints.
+func loadIndexes(ctx context.Context, tx pgx.Tx, schemas []Schema) error {
+	tables, schemaNames, tableNames := tableIndex(schemas)
+	if len(tables) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, `
+        SELECT
+            n.nspname,
+            c.relname,
+            i.relname,
+            x.indisunique,
+            pg_get_indexdef(x.indexrelid),
+            ARRAY(
+                SELECT DISTINCT a.attname
+                FROM pg_depend d
+                JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+                WHERE d.classid = 'pg_class'::regclass
+                AND d.objid = x.indexrelid
+                AND d.refobjid = x.indrelid
+                AND d.refobjsubid > 0
+            )
+        FROM pg_index x
+        JOIN pg_class i ON i.oid = x.indexrelid
+        JOIN pg_class c ON c.oid = x.indrelid
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        WHERE (n.nspname, c.relname) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+        AND NOT EXISTS (
+            SELECT 1 FROM pg_constraint con
+            WHERE con.conindid = x.indexrelid
+            AND con.conrelid = x.indrelid
+            AND con.contype IN ('p', 'u', 'x')
+        )
+        ORDER BY n.nspname, c.relname, i.relname
+    `, schemaNames, tableNames)
+	if err != nil {
+		return fmt.Errorf("index query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName string
+		var index Index
+		if err := rows.Scan(
+			&schemaName, &tableName,
+			&index.Name, &index.Unique, &index.Definition, &index.Columns,
+		); err != nil {
+			return fmt.Errorf("scan failed: %w", err)
+		}
+		table := tables[[2]string{schemaName, tableName}]
+		table.Indexes = append(table.Indexes, index)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("row iteration failed: %w", err)
+	}
+	return nil
+}
@@ -5,13 +5,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"net/url"
+	"net"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kerem-kaynak/llmshark/internal/storage"
+	"golang.org/x/crypto/ssh"
 )
 
 type Schema struct {
@@ -25,6 +28,8 @@ type Table struct {
 	Name        string
 	Description string
 	Columns     []Column
+	Constraints []Constraint
+	Indexes     []Index
 	Selected    bool
 	Expanded    bool
 }
@@ -39,54 +44,191 @@ type Column struct {
 	IsPrimary   bool
 	IsUnique    bool
 	Constraints []string
-	Selected    bool
+	// Identity is IdentityAlways or IdentityByDefault for identity columns.
+	Identity string
+	// Generated is set for stored generated columns, whose expression is
+	// then held in Default.
+	Generated bool
+	// Enum is the enum type of the column or of its array elements.
+	Enum     *Enum
+	Selected bool
 }
 
-type SchemaFilter struct {
-	ExcludeSchemas []string
-	IncludeSchemas []string
-}
+const (
+	defaultMaxConns       = 4
+	defaultMinConns       = 1
+	defaultConnectTimeout = 5 * time.Second
+)
 
-var DefaultSchemaFilter = SchemaFilter{
-	ExcludeSchemas: []string{"pg_catalog", "information_schema"},
+// queryExecModes maps storage.QueryModes to pgx query modes that work without
+// server-side prepared statements.
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"exec":   pgx.QueryExecModeExec,
+	"simple": pgx.QueryExecModeSimpleProtocol,
 }
 
 type Client struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	tunnel *ssh.Client
+
+	database      string
+	serverVersion string
 }
 
-func NewClient(ctx context.Context, creds *storage.Credentials) (*Client, error) {
-	// URL-encode the username and password
-	user := url.QueryEscape(creds.User)
-	password := url.QueryEscape(creds.Password)
+// connString returns the connection string for creds. Unless the user gave
+// one explicitly, it is built as a libpq keyword/value string with empty
+// settings left out so libpq defaults apply to them.
+func connString(creds *storage.Credentials) string {
+	if creds.ConnString != "" {
+		return creds.ConnString
+	}
 
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=require",
-		user, password, creds.Host, creds.Port, creds.Database)
+	settings := []struct {
+		key   string
+		value string
+	}{
+		{"service", creds.Service},
+		{"host", creds.Host},
+		{"port", creds.Port},
+		{"dbname", creds.Database},
+		{"user", creds.User},
+		{"password", creds.Password},
+		{"sslmode", creds.SSLMode},
+		{"sslrootcert", storage.ExpandHome(creds.SSLRootCert)},
+		{"sslcert", storage.ExpandHome(creds.SSLCert)},
+		{"sslkey", storage.ExpandHome(creds.SSLKey)},
+	}
+
+	parts := make([]string, 0, len(settings))
+	for _, s := range settings {
+		if s.value == "" {
+			continue
+		}
+		parts = append(parts, s.key+"="+quoteConnValue(s.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteConnValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+// ValidateConnString checks that s is a connection string pgx can use,
+// either a postgres:// URI or a keyword/value DSN.
+func ValidateConnString(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return errors.New("connection string cannot be empty")
+	}
+	if _, err := pgxpool.ParseConfig(s); err != nil {
+		return fmt.Errorf("invalid connection string: %w", err)
+	}
+	return nil
+}
 
-	config, err := pgxpool.ParseConfig(connStr)
+// poolConfig returns the pool configuration for creds.
+func poolConfig(creds *storage.Credentials) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(connString(creds))
 	if err != nil {
 		return nil, fmt.Errorf("invalid connection config: %w", err)
 	}
 
-	config.MaxConns = 4
-	config.MinConns = 1
+	if mode, ok := queryExecModes[creds.QueryMode]; ok {
+		// Poolers reject unknown startup parameters, so sessions rely on the
+		// read-only transaction every read runs in, see beginReadOnly
+		config.ConnConfig.DefaultQueryExecMode = mode
+	} else {
+		// Everything except explicit comment edits is read-only, so browsing
+		// can never modify the database, even by a read that doesn't use
+		// beginReadOnly
+		config.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+
+	config.MaxConns = defaultMaxConns
+	if creds.MaxConns > 0 {
+		config.MaxConns = int32(creds.MaxConns)
+	}
+	config.MinConns = defaultMinConns
+	if creds.MinConns > 0 {
+		config.MinConns = int32(creds.MinConns)
+	}
+	config.MinConns = min(config.MinConns, config.MaxConns)
 	config.MaxConnLifetime = time.Hour
 	config.MaxConnIdleTime = 30 * time.Minute
+	return config, nil
+}
+
+func NewClient(ctx context.Context, creds *storage.Credentials) (*Client, error) {
+	if err := creds.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid connection config: %w", err)
+	}
+
+	config, err := poolConfig(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers may set their own deadline for connecting
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultConnectTimeout)
+		defer cancel()
+	}
+
+	var tunnel *ssh.Client
+	if creds.SSH.Enabled() {
+		tunnel, err = openTunnel(ctx, creds.SSH)
+		if err != nil {
+			return nil, err
+		}
+
+		// Database host names are resolved and dialed on the bastion,
+		// where they may not be reachable from here.
+		config.ConnConfig.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+			return []string{host}, nil
+		}
+		config.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return tunnel.DialContext(ctx, network, addr)
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	client := &Client{tunnel: tunnel}
 
-	pool, err := pgxpool.NewWithConfig(ctx, config)
+	client.pool, err = pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 
-	if err := pool.Ping(ctx); err != nil {
-		pool.Close()
+	if err := client.loadServerInfo(ctx); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("connection test failed: %w", err)
 	}
 
-	return &Client{pool: pool}, nil
+	return client, nil
+}
+
+// loadServerInfo reads the database name and server version, which also
+// tests the connection.
+func (c *Client) loadServerInfo(ctx context.Context) error {
+	tx, err := c.beginReadOnly(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	return tx.QueryRow(ctx, "SELECT current_database(), current_setting('server_version')").
+		Scan(&c.database, &c.serverVersion)
+}
+
+// Database returns the name of the connected database.
+func (c *Client) Database() string {
+	return c.database
+}
+
+// ServerVersion returns the version of the PostgreSQL server, such as 16.2.
+func (c *Client) ServerVersion() string {
+	return c.serverVersion
 }
 
 func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema, error) {
@@ -95,12 +237,14 @@ func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema,
         schemas AS (
             SELECT n.nspname, n.oid
             FROM pg_namespace n
-            WHERE n.nspname = ANY($1::text[])
-            OR (
-                n.nspname != ALL($2::text[])
-                AND n.nspname NOT LIKE 'pg_%'
-                AND n.nspname != 'information_schema'
-                AND array_length($1::text[], 1) IS NULL
+            WHERE NOT n.nspname ~ ANY($2::text[])
+            AND (
+                n.nspname ~ ANY($1::text[])
+                OR (
+                    cardinality($1::text[]) = 0
+                    AND n.nspname NOT LIKE 'pg_%'
+                    AND n.nspname != 'information_schema'
+                )
             )
         ),
         base_tables AS (
@@ -113,6 +257,13 @@ func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema,
             JOIN pg_class c ON c.relnamespace = s.oid
             WHERE c.relkind = 'r'
             AND NOT c.relispartition
+            AND (
+                cardinality($3::text[]) = 0
+                OR c.relname ~ ANY($3::text[])
+                OR (s.nspname || '.' || c.relname) ~ ANY($3::text[])
+            )
+            AND NOT c.relname ~ ANY($4::text[])
+            AND NOT (s.nspname || '.' || c.relname) ~ ANY($4::text[])
         ),
         columns AS (
             SELECT 
@@ -123,6 +274,8 @@ func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema,
                 pg_catalog.format_type(a.atttypid, a.atttypmod) as column_type,
                 col_description(t.table_oid, a.attnum) as column_description,
                 a.attnotnull as not_null,
+                a.attidentity::text as identity,
+                a.attgenerated = 's' as generated,
                 a.atthasdef as has_default,
                 pg_get_expr(d.adbin, d.adrelid) as column_default,
                 EXISTS (
@@ -138,13 +291,24 @@ func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema,
                     AND a.attnum = ANY(c.conkey)
                 ) as is_unique,
                 (
-                    SELECT array_agg(c.conname) 
+                    SELECT array_agg(c.conname ORDER BY c.conname)
                     FROM pg_constraint c 
                     WHERE c.conrelid = t.table_oid 
                     AND a.attnum = ANY(c.conkey)
-                ) as constraints
+                ) as constraints,
+                etn.nspname as enum_schema,
+                et.typname as enum_name,
+                (
+                    SELECT array_agg(e.enumlabel ORDER BY e.enumsortorder)
+                    FROM pg_enum e
+                    WHERE e.enumtypid = et.oid
+                ) as enum_labels
             FROM base_tables t
             JOIN pg_attribute a ON a.attrelid = t.table_oid
+            JOIN pg_type ty ON ty.oid = a.atttypid
+            LEFT JOIN pg_type et ON et.oid = CASE WHEN ty.typcategory = 'A' THEN ty.typelem ELSE ty.oid END
+                AND et.typtype = 'e'
+            LEFT JOIN pg_namespace etn ON etn.oid = et.typnamespace
             LEFT JOIN pg_attrdef d ON d.adrelid = t.table_oid AND d.adnum = a.attnum
             WHERE a.attnum > 0 
             AND NOT a.attisdropped
@@ -153,35 +317,58 @@ func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema,
         SELECT * FROM columns;
     `
 
-	includeSchemas := filter.IncludeSchemas
-	excludeSchemas := filter.ExcludeSchemas
-	if len(excludeSchemas) == 0 {
-		excludeSchemas = DefaultSchemaFilter.ExcludeSchemas
+	// System schemas are skipped unless the filter names schemas itself, so
+	// explicitly included schemas are loaded even if excluded by default
+	if len(filter.ExcludeSchemas) == 0 && len(filter.IncludeSchemas) == 0 {
+		filter.ExcludeSchemas = DefaultSchemaFilter.ExcludeSchemas
+	}
+
+	// Patterns are matched by the server so filtered tables are never read
+	var args [4][]string
+	for i, patterns := range [][]string{filter.IncludeSchemas, filter.ExcludeSchemas, filter.IncludeTables, filter.ExcludeTables} {
+		args[i] = patternRegexps(patterns)
+	}
+
+	tx, err := c.beginReadOnly(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("schema query failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Name the offending pattern rather than failing the whole query
+	if err := checkPatterns(ctx, tx, filter); err != nil {
+		return nil, err
 	}
 
-	rows, err := c.pool.Query(ctx, query, includeSchemas, excludeSchemas)
+	rows, err := tx.Query(ctx, query, args[0], args[1], args[2], args[3])
 	if err != nil {
 		return nil, fmt.Errorf("schema query failed: %w", err)
 	}
 	defer rows.Close()
 
 	schemaMap := make(map[string]*Schema)
+	enums := enumCache{}
 
 	for rows.Next() {
 		var (
 			schemaName, tableName                string
 			tableDesc, colName, colType, colDesc sql.NullString
 			notNull, hasDefault                  bool
+			identity                             string
+			generated                            bool
 			colDefault                           sql.NullString
 			isPrimary, isUnique                  bool
 			constraints                          []string
+			enumSchema, enumName                 sql.NullString
+			enumLabels                           []string
 		)
 
 		if err := rows.Scan(
 			&schemaName, &tableName, &tableDesc,
 			&colName, &colType, &colDesc,
-			&notNull, &hasDefault, &colDefault,
+			&notNull, &identity, &generated, &hasDefault, &colDefault,
 			&isPrimary, &isUnique, &constraints,
+			&enumSchema, &enumName, &enumLabels,
 		); err != nil {
 			return nil, fmt.Errorf("scan failed: %w", err)
 		}
@@ -226,6 +413,11 @@ func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema,
 			IsPrimary:   isPrimary,
 			IsUnique:    isUnique,
 			Constraints: constraints,
+			Identity:    identity,
+			Generated:   generated,
+		}
+		if enumName.Valid {
+			column.Enum = enums.get(enumSchema.String, enumName.String, enumLabels)
 		}
 		table.Columns = append(table.Columns, column)
 	}
@@ -234,11 +426,21 @@ func (c *Client) GetSchemas(ctx context.Context, filter SchemaFilter) ([]Schema,
 		return nil, fmt.Errorf("row iteration failed: %w", err)
 	}
 
-	// Convert map to slice
+	// Convert map to slice, sorted by name so exports are reproducible
 	schemas := make([]Schema, 0, len(schemaMap))
 	for _, schema := range schemaMap {
 		schemas = append(schemas, *schema)
 	}
+	slices.SortFunc(schemas, func(a, b Schema) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if err := loadConstraints(ctx, tx, schemas); err != nil {
+		return nil, err
+	}
+	if err := loadIndexes(ctx, tx, schemas); err != nil {
+		return nil, err
+	}
 
 	return schemas, nil
 }
@@ -331,11 +533,21 @@ func (c *Client) UpdateComment(ctx context.Context, schema, table, column, comme
 			sanitizedComment)
 	}
 
-	// Execute the query
-	_, err = c.pool.Exec(ctx, query)
+	// Sessions are read-only by default, so the write needs its own
+	// explicitly read-write transaction
+	tx, err := c.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
 	if err != nil {
 		return fmt.Errorf("failed to update comment: %w", err)
 	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
 
 	return nil
 }
@@ -363,8 +575,14 @@ func (c *Client) VerifyComment(ctx context.Context, schema, table, column string
 		args = []interface{}{schema, table, column}
 	}
 
+	tx, err := c.beginReadOnly(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify comment: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	var comment sql.NullString
-	err := c.pool.QueryRow(ctx, query, args...).Scan(&comment)
+	err = tx.QueryRow(ctx, query, args...).Scan(&comment)
 	if err != nil {
 		return "", fmt.Errorf("failed to verify comment: %w", err)
 	}
@@ -372,8 +590,18 @@ func (c *Client) VerifyComment(ctx context.Context, schema, table, column string
 	return comment.String, nil
 }
 
+// beginReadOnly starts an explicitly read-only transaction. Every read runs
+// in one, which repeats the session default where it is set and keeps
+// reads read-only behind connection poolers, where it can't be.
+func (c *Client) beginReadOnly(ctx context.Context) (pgx.Tx, error) {
+	return c.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+}
+
 func (c *Client) Close() {
 	if c.pool != nil {
 		c.pool.Close()
 	}
+	if c.tunnel != nil {
+		c.tunnel.Close()
+	}
 }
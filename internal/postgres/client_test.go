@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+)
+
+func TestPoolConfigReadOnlySession(t *testing.T) {
+	tests := []struct {
+		queryMode string
+		readOnly  bool
+		execMode  pgx.QueryExecMode
+	}{
+		{"", true, pgx.QueryExecModeCacheStatement},
+		{"exec", false, pgx.QueryExecModeExec},
+		{"simple", false, pgx.QueryExecModeSimpleProtocol},
+	}
+	for _, tt := range tests {
+		creds := &storage.Credentials{
+			Host:      "localhost",
+			Port:      "5432",
+			User:      "reader",
+			Database:  "shop",
+			SSLMode:   "disable",
+			QueryMode: tt.queryMode,
+		}
+		config, err := poolConfig(creds)
+		if err != nil {
+			t.Fatalf("query mode %q: %v", tt.queryMode, err)
+		}
+
+		value, ok := config.ConnConfig.RuntimeParams["default_transaction_read_only"]
+		if tt.readOnly && value != "on" {
+			t.Errorf("query mode %q: default_transaction_read_only = %q, want on", tt.queryMode, value)
+		}
+		if !tt.readOnly && ok {
+			t.Errorf("query mode %q: default_transaction_read_only is sent, which poolers reject", tt.queryMode)
+		}
+		if got := config.ConnConfig.DefaultQueryExecMode; got != tt.execMode {
+			t.Errorf("query mode %q: exec mode = %v, want %v", tt.queryMode, got, tt.execMode)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"os"
+
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+)
+
+// libpqEnvVars are the environment variables that, when set, indicate the
+// user has configured a connection for libpq tools such as psql.
+var libpqEnvVars = []string{
+	"PGHOST",
+	"PGHOSTADDR",
+	"PGPORT",
+	"PGDATABASE",
+	"PGUSER",
+	"PGSERVICE",
+}
+
+// EnvironmentCredentials returns credentials that defer entirely to libpq
+// defaults: PG* environment variables, the service file and ~/.pgpass, all of
+// which pgx reads while parsing the connection string. If service is empty
+// and no libpq environment variable is set, it returns nil.
+func EnvironmentCredentials(service string) *storage.Credentials {
+	if service != "" {
+		return &storage.Credentials{Service: service}
+	}
+
+	for _, name := range libpqEnvVars {
+		if os.Getenv(name) != "" {
+			return &storage.Credentials{}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SchemaFilter limits the catalog to matching schemas and tables. Each
+// entry is a glob pattern such as "sales_*", or a regular expression when
+// prefixed with "re:". Regular expressions are matched by the server with
+// the ~ operator, so they use PostgreSQL's syntax. Table patterns match
+// either the table name or the schema-qualified name, e.g. "audit.*".
+// Empty include lists match everything.
+type SchemaFilter struct {
+	ExcludeSchemas []string
+	IncludeSchemas []string
+	ExcludeTables  []string
+	IncludeTables  []string
+}
+
+var DefaultSchemaFilter = SchemaFilter{
+	ExcludeSchemas: []string{"pg_catalog", "information_schema"},
+}
+
+// regexPrefix marks a pattern as a regular expression rather than a glob.
+const regexPrefix = "re:"
+
+// invalidRegularExpression is the SQLSTATE of a regular expression the
+// server can't compile.
+const invalidRegularExpression = "2201B"
+
+// ErrInvalidPattern is returned by GetSchemas when the server rejects a
+// regular expression of the filter.
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// checkPatterns compiles the regular expressions of f with the engine that
+// runs them, since Go's syntax differs from PostgreSQL's. A rejected
+// pattern aborts tx.
+func checkPatterns(ctx context.Context, tx pgx.Tx, f SchemaFilter) error {
+	for _, patterns := range [][]string{f.IncludeSchemas, f.ExcludeSchemas, f.IncludeTables, f.ExcludeTables} {
+		for _, p := range patterns {
+			expr, ok := strings.CutPrefix(p, regexPrefix)
+			if !ok {
+				continue
+			}
+			_, err := tx.Exec(ctx, "SELECT '' ~ $1", expr)
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == invalidRegularExpression {
+				return fmt.Errorf("%w %q: %s", ErrInvalidPattern, p, pgErr.Message)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// patternRegexps converts patterns to the regular expressions matched in
+// the catalog query. The result is never nil, since the query treats a
+// NULL array differently from an empty one.
+func patternRegexps(patterns []string) []string {
+	exprs := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		exprs = append(exprs, patternRegexp(p))
+	}
+	return exprs
+}
+
+func patternRegexp(pattern string) string {
+	if expr, ok := strings.CutPrefix(pattern, regexPrefix); ok {
+		return expr
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
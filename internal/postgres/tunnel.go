@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultSSHPort = "22"
+
+// openTunnel connects to the bastion host described by t. Database
+// connections are then opened through the returned client with Dial.
+func openTunnel(ctx context.Context, t storage.SSHTunnel) (*ssh.Client, error) {
+	auth, closeAgent, err := sshAuth(t)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	hostKeyCallback, err := sshHostKeyCallback(t)
+	if err != nil {
+		return nil, err
+	}
+
+	user := t.User
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+
+	port := t.Port
+	if port == "" {
+		port = defaultSSHPort
+	}
+	addr := net.JoinHostPort(t.Host, port)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach SSH host: %w", err)
+	}
+
+	// The handshake has no context support, so bound it with the deadline.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		conn.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("host key for %s is not in known_hosts; connect once with ssh to add it", t.Host)
+		}
+		return nil, fmt.Errorf("SSH handshake failed: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// sshAuth returns the key file or ssh-agent auth method for t, along with
+// a function releasing the agent connection once the handshake is done.
+func sshAuth(t storage.SSHTunnel) (ssh.AuthMethod, func(), error) {
+	if t.KeyFile != "" {
+		key, err := os.ReadFile(storage.ExpandHome(t.KeyFile))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read SSH key: %w", err)
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				return nil, nil, errors.New("SSH key is passphrase protected; add it to ssh-agent and leave the key file empty")
+			}
+			return nil, nil, fmt.Errorf("failed to parse SSH key: %w", err)
+		}
+		return ssh.PublicKeys(signer), func() {}, nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, errors.New("no SSH key file given and no ssh-agent is running")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), func() { conn.Close() }, nil
+}
+
+func sshHostKeyCallback(t storage.SSHTunnel) (ssh.HostKeyCallback, error) {
+	if t.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path := storage.ExpandHome(t.KnownHostsFile)
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts: %w", err)
+	}
+	return callback, nil
+}
@@ -0,0 +1,356 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer is an in-process bastion that accepts one client key and
+// forwards direct-tcpip channels, as ssh -L does.
+type sshServer struct {
+	addr    string
+	hostKey ssh.Signer
+}
+
+func newSSHServer(t *testing.T, authorized ssh.PublicKey) *sshServer {
+	t.Helper()
+
+	hostKey := newSigner(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config)
+		}
+	}()
+	return &sshServer{addr: ln.Addr().String(), hostKey: hostKey}
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "direct-tcpip" {
+			newChan.Reject(ssh.UnknownChannelType, "only direct-tcpip is supported")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(newChan.ExtraData(), &target); err != nil {
+			newChan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelReqs, err := newChan.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelReqs)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// port returns the port of the server, which tunnels are configured with
+// separately from the host.
+func (s *sshServer) port() string {
+	_, port, _ := net.SplitHostPort(s.addr)
+	return port
+}
+
+// knownHosts writes a known_hosts file listing key for the server.
+func (s *sshServer) knownHosts(t *testing.T, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, key)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	signer, err := ssh.NewSignerFromKey(newKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// writeKeyFile writes key in OpenSSH format and returns its path.
+func writeKeyFile(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startAgent serves key from an in-process ssh-agent and points
+// SSH_AUTH_SOCK at it.
+func startAgent(t *testing.T, key ed25519.PrivateKey) {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+}
+
+// startEcho starts a TCP server that writes back what it reads, standing in
+// for the database behind the bastion.
+func startEcho(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// checkEcho dials the echo server through client and checks a round trip.
+func checkEcho(t *testing.T, client *ssh.Client, echoAddr string) {
+	t.Helper()
+	conn, err := client.DialContext(testContext(t), "tcp", echoAddr)
+	if err != nil {
+		t.Fatalf("dialing through the tunnel: %v", err)
+	}
+	defer conn.Close()
+
+	want := []byte("SELECT 1")
+	if _, err := conn.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("echo through the tunnel = %q, want %q", got, want)
+	}
+}
+
+func TestOpenTunnelKeyFile(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	key := newKey(t)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newSSHServer(t, signer.PublicKey())
+
+	client, err := openTunnel(testContext(t), storage.SSHTunnel{
+		Host:           "127.0.0.1",
+		Port:           server.port(),
+		User:           "tunnel",
+		KeyFile:        writeKeyFile(t, key),
+		KnownHostsFile: server.knownHosts(t, server.hostKey.PublicKey()),
+	})
+	if err != nil {
+		t.Fatalf("openTunnel: %v", err)
+	}
+	defer client.Close()
+
+	checkEcho(t, client, startEcho(t))
+}
+
+func TestOpenTunnelAgent(t *testing.T) {
+	key := newKey(t)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newSSHServer(t, signer.PublicKey())
+	startAgent(t, key)
+
+	client, err := openTunnel(testContext(t), storage.SSHTunnel{
+		Host:           "127.0.0.1",
+		Port:           server.port(),
+		User:           "tunnel",
+		KnownHostsFile: server.knownHosts(t, server.hostKey.PublicKey()),
+	})
+	if err != nil {
+		t.Fatalf("openTunnel: %v", err)
+	}
+	defer client.Close()
+
+	checkEcho(t, client, startEcho(t))
+}
+
+func TestOpenTunnelRejectsHostKey(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	key := newKey(t)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newSSHServer(t, signer.PublicKey())
+	keyFile := writeKeyFile(t, key)
+
+	emptyKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(emptyKnownHosts, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("unknown host", func(t *testing.T) {
+		client, err := openTunnel(testContext(t), storage.SSHTunnel{
+			Host:           "127.0.0.1",
+			Port:           server.port(),
+			User:           "tunnel",
+			KeyFile:        keyFile,
+			KnownHostsFile: emptyKnownHosts,
+		})
+		if err == nil {
+			client.Close()
+			t.Fatal("openTunnel succeeded for a host missing from known_hosts")
+		}
+		if !strings.Contains(err.Error(), "not in known_hosts") {
+			t.Fatalf("openTunnel error = %v, want the host reported as missing from known_hosts", err)
+		}
+	})
+
+	t.Run("mismatched key", func(t *testing.T) {
+		client, err := openTunnel(testContext(t), storage.SSHTunnel{
+			Host:           "127.0.0.1",
+			Port:           server.port(),
+			User:           "tunnel",
+			KeyFile:        keyFile,
+			KnownHostsFile: server.knownHosts(t, newSigner(t).PublicKey()),
+		})
+		if err == nil {
+			client.Close()
+			t.Fatal("openTunnel succeeded although known_hosts lists another key")
+		}
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+			t.Fatalf("openTunnel error = %v, want a known_hosts mismatch", err)
+		}
+	})
+}
+
+func TestOpenTunnelRejectsUnauthorizedKey(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := newSSHServer(t, newSigner(t).PublicKey())
+
+	client, err := openTunnel(testContext(t), storage.SSHTunnel{
+		Host:           "127.0.0.1",
+		Port:           server.port(),
+		User:           "tunnel",
+		KeyFile:        writeKeyFile(t, newKey(t)),
+		KnownHostsFile: server.knownHosts(t, server.hostKey.PublicKey()),
+	})
+	if err == nil {
+		client.Close()
+		t.Fatal("openTunnel succeeded with a key the server doesn't accept")
+	}
+}
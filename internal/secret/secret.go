@@ -0,0 +1,113 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kerem-kaynak/llmshark/internal/storage"
+)
+
+// Reference prefixes, shared with the credential store that keeps
+// references instead of secrets.
+const (
+	envPrefix  = storage.EnvReference
+	filePrefix = storage.FileReference
+	cmdPrefix  = storage.CmdReference
+)
+
+const commandTimeout = 30 * time.Second
+
+// IsReference reports whether s refers to a secret held elsewhere rather
+// than being the secret itself.
+func IsReference(s string) bool {
+	return storage.IsPasswordReference(s)
+}
+
+// Resolve returns the secret ref points to:
+//
+//	env:NAME      the value of environment variable NAME
+//	file:PATH     the contents of the file at PATH
+//	cmd:COMMAND   the output of COMMAND, run by the shell
+//
+// Trailing newlines are removed from file contents and command output.
+// Strings that aren't references are returned unchanged.
+func Resolve(ctx context.Context, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, envPrefix):
+		name := strings.TrimPrefix(ref, envPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+
+	case strings.HasPrefix(ref, filePrefix):
+		path := storage.ExpandHome(strings.TrimPrefix(ref, filePrefix))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(ref, cmdPrefix):
+		return runCommand(ctx, strings.TrimPrefix(ref, cmdPrefix))
+	}
+
+	return ref, nil
+}
+
+// ResolveCredentials returns creds with a password reference replaced by
+// the secret it points to. It works on a copy so the secret itself never
+// reaches the credential store.
+//
+// Both ways of connecting need this: the explorer, and the export command,
+// which connects without the TUI. Sharing it keeps the two from resolving
+// references differently.
+func ResolveCredentials(ctx context.Context, creds *storage.Credentials) (*storage.Credentials, error) {
+	if !IsReference(creds.Password) {
+		return creds, nil
+	}
+	password, err := Resolve(ctx, creds.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve password: %w", err)
+	}
+	resolved := *creds
+	resolved.Password = password
+	return &resolved, nil
+}
+
+func runCommand(ctx context.Context, command string) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", errors.New("secret command is empty")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("secret command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("secret command failed: %w", err)
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
@@ -0,0 +1,106 @@
+// Searches the index in search-index.js as you type. Every word typed must
+// appear in the name or description of a result; names matching the query
+// come first.
+(function () {
+  var input = document.getElementById("search");
+  var list = document.getElementById("search-results");
+  var root = input.dataset.root;
+  var kinds = { schema: 0, table: 1, column: 2 };
+  var limit = 50;
+  var active = -1;
+
+  function search(query) {
+    var words = query.toLowerCase().split(/\s+/).filter(Boolean);
+    if (words.length === 0) {
+      return [];
+    }
+    var results = [];
+    for (var i = 0; i < searchIndex.length; i++) {
+      var entry = searchIndex[i];
+      var name = entry.name.toLowerCase();
+      var text = name + " " + (entry.description || "").toLowerCase();
+      if (words.every(function (w) { return text.indexOf(w) !== -1; })) {
+        var rank = words.every(function (w) { return name.indexOf(w) !== -1; }) ? 0 : 1;
+        results.push({ entry: entry, rank: rank });
+      }
+    }
+    results.sort(function (a, b) {
+      return a.rank - b.rank ||
+        kinds[a.entry.kind] - kinds[b.entry.kind] ||
+        a.entry.name.length - b.entry.name.length;
+    });
+    return results.slice(0, limit).map(function (r) { return r.entry; });
+  }
+
+  function show(results) {
+    list.textContent = "";
+    active = -1;
+    results.forEach(function (entry) {
+      var link = document.createElement("a");
+      link.href = root + entry.url;
+
+      var kind = document.createElement("span");
+      kind.className = "kind";
+      kind.textContent = entry.kind;
+      link.appendChild(kind);
+      link.appendChild(document.createTextNode(entry.name));
+
+      if (entry.description) {
+        var description = document.createElement("span");
+        description.className = "description";
+        description.textContent = entry.description;
+        link.appendChild(description);
+      }
+
+      var item = document.createElement("li");
+      item.appendChild(link);
+      list.appendChild(item);
+    });
+    list.hidden = results.length === 0;
+  }
+
+  function highlight(index) {
+    var items = list.children;
+    if (items.length === 0) {
+      return;
+    }
+    if (active >= 0) {
+      items[active].classList.remove("active");
+    }
+    active = (index + items.length) % items.length;
+    items[active].classList.add("active");
+    items[active].scrollIntoView({ block: "nearest" });
+  }
+
+  input.addEventListener("input", function () {
+    show(search(input.value));
+  });
+
+  input.addEventListener("keydown", function (e) {
+    if (e.key === "ArrowDown") {
+      highlight(active + 1);
+      e.preventDefault();
+    } else if (e.key === "ArrowUp") {
+      highlight(active - 1);
+      e.preventDefault();
+    } else if (e.key === "Enter" && list.children.length > 0) {
+      list.children[Math.max(active, 0)].querySelector("a").click();
+    } else if (e.key === "Escape") {
+      input.value = "";
+      show([]);
+    }
+  });
+
+  document.addEventListener("keydown", function (e) {
+    if (e.key === "/" && document.activeElement !== input) {
+      input.focus();
+      e.preventDefault();
+    }
+  });
+
+  document.addEventListener("click", function (e) {
+    if (!input.parentNode.contains(e.target)) {
+      list.hidden = true;
+    }
+  });
+})();
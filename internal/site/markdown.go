@@ -0,0 +1,203 @@
+package site
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// markdown renders a comment written in Markdown as HTML. It covers what
+// comments use in practice: paragraphs, headings, lists, quotes, fenced
+// code, code spans, emphasis and links. Everything else is shown as text,
+// and raw HTML is escaped rather than passed through.
+func markdown(s string) template.HTML {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(s), "\r\n", "\n"), "\n")
+
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			i++
+			var code []string
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // closing fence
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+
+		case headingPattern.MatchString(trimmed):
+			m := headingPattern.FindStringSubmatch(trimmed)
+			// Comments sit below the page's own headings
+			level := min(len(m[1])+3, 6)
+			tag := "h" + strconv.Itoa(level)
+			b.WriteString("<" + tag + ">" + inline(m[2]) + "</" + tag + ">\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quote []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				quote = append(quote, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")))
+				i++
+			}
+			b.WriteString("<blockquote><p>" + inline(strings.Join(quote, "\n")) + "</p></blockquote>\n")
+
+		case listItem(trimmed) != "":
+			tag := listItem(trimmed)
+			b.WriteString("<" + tag + ">\n")
+			for i < len(lines) && listItem(strings.TrimSpace(lines[i])) == tag {
+				item := []string{listPattern.ReplaceAllString(strings.TrimSpace(lines[i]), "")}
+				i++
+				// Indented lines continue the item
+				for i < len(lines) && strings.TrimSpace(lines[i]) != "" && lines[i] != strings.TrimLeft(lines[i], " \t") {
+					item = append(item, strings.TrimSpace(lines[i]))
+					i++
+				}
+				b.WriteString("<li>" + inline(strings.Join(item, "\n")) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+
+		default:
+			var para []string
+			for i < len(lines) && startsParagraph(lines[i]) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			b.WriteString("<p>" + inline(strings.Join(para, "\n")) + "</p>\n")
+		}
+	}
+	return template.HTML(b.String())
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	listPattern    = regexp.MustCompile(`^([-*+]|\d{1,9}[.)])\s+`)
+	urlPattern     = regexp.MustCompile(`^https?://[^\s<>"]*[^\s<>".,;:!?)\]']`)
+)
+
+// listItem returns the list element a line starts, ul or ol, or "".
+func listItem(line string) string {
+	m := listPattern.FindString(line)
+	switch {
+	case m == "":
+		return ""
+	case strings.ContainsAny(m[:1], "-*+"):
+		return "ul"
+	default:
+		return "ol"
+	}
+}
+
+// startsParagraph reports whether line continues a paragraph rather than
+// starting another block.
+func startsParagraph(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" &&
+		!strings.HasPrefix(trimmed, "```") &&
+		!strings.HasPrefix(trimmed, ">") &&
+		!headingPattern.MatchString(trimmed) &&
+		listItem(trimmed) == ""
+}
+
+// inline renders code spans, emphasis and links in s, escaping the rest.
+func inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_[]()#+-.!>", rune(rest[1])):
+			b.WriteString(html.EscapeString(rest[1:2]))
+			i += 2
+			continue
+
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				b.WriteString("<code>" + html.EscapeString(rest[1:end+1]) + "</code>")
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				b.WriteString("<strong>" + inline(rest[2:end+2]) + "</strong>")
+				i += end + 4
+				continue
+			}
+
+		case rest[0] == '*' || (rest[0] == '_' && wordBoundary(s, i)):
+			if end := strings.IndexByte(rest[1:], rest[0]); end > 0 && rest[1] != ' ' {
+				b.WriteString("<em>" + inline(rest[1:end+1]) + "</em>")
+				i += end + 2
+				continue
+			}
+
+		case rest[0] == '[':
+			if text, target, n := parseLink(rest); n > 0 {
+				if href, ok := safeURL(target); ok {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `">` + inline(text) + "</a>")
+				} else {
+					b.WriteString(inline(text))
+				}
+				i += n
+				continue
+			}
+
+		case (rest[0] == 'h') && wordBoundary(s, i):
+			if u := urlPattern.FindString(rest); u != "" {
+				b.WriteString(`<a href="` + html.EscapeString(u) + `">` + html.EscapeString(u) + "</a>")
+				i += len(u)
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(rest[:1]))
+		i++
+	}
+	return b.String()
+}
+
+// wordBoundary reports whether s[i] starts a word.
+func wordBoundary(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	c := s[i-1]
+	return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_')
+}
+
+// parseLink parses [text](target) at the start of s, returning its length
+// or 0 if there is none.
+func parseLink(s string) (string, string, int) {
+	mid := strings.Index(s, "](")
+	if mid < 1 || strings.ContainsRune(s[1:mid], ']') {
+		return "", "", 0
+	}
+	end := strings.IndexByte(s[mid+2:], ')')
+	if end < 0 {
+		return "", "", 0
+	}
+	return s[1:mid], strings.TrimSpace(s[mid+2 : mid+2+end]), mid + 3 + end
+}
+
+// safeURL returns target if it is a web, mail or relative link. Other
+// schemes such as javascript: are dropped.
+func safeURL(target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil || target == "" {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return u.String(), true
+	}
+	return "", false
+}
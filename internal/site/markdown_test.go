@@ -0,0 +1,61 @@
+package site
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownDropsUnsafeLinks(t *testing.T) {
+	for _, target := range []string{
+		"javascript:alert(1)",
+		"JaVaScRiPt:alert(1)",
+		" javascript:alert(1)",
+		"vbscript:msgbox(1)",
+		"data:text/html,<script>alert(1)</script>",
+		"java\tscript:alert(1)",
+	} {
+		got := string(markdown("[click](" + target + ")"))
+		if strings.Contains(strings.ToLower(got), "href") {
+			t.Errorf("markdown link to %q = %q, want no link", target, got)
+		}
+		if !strings.Contains(got, "click") {
+			t.Errorf("markdown link to %q = %q, want its text kept", target, got)
+		}
+	}
+}
+
+func TestMarkdownKeepsSafeLinks(t *testing.T) {
+	tests := map[string]string{
+		"[docs](https://example.com/a?b=1&c=2)": `<a href="https://example.com/a?b=1&amp;c=2">docs</a>`,
+		"[mail](mailto:team@example.com)":       `<a href="mailto:team@example.com">mail</a>`,
+		"see https://example.com.":              `<a href="https://example.com">https://example.com</a>.`,
+	}
+	for in, want := range tests {
+		if got := string(markdown(in)); !strings.Contains(got, want) {
+			t.Errorf("markdown(%q) = %q, want it to contain %q", in, got, want)
+		}
+	}
+}
+
+func TestMarkdownEscapesHTML(t *testing.T) {
+	for _, in := range []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"# <b>heading</b>",
+		"- <i>item</i>",
+		"> <iframe src=x>",
+		"```\n<script>alert(1)</script>\n```",
+		"`<script>`",
+		"**<b>bold</b>**",
+		`[<b>text</b>](https://example.com)`,
+		`[x](https://example.com/"onmouseover="alert(1))`,
+		`https://example.com/"onmouseover="alert(1)`,
+	} {
+		got := string(markdown(in))
+		for _, bad := range []string{"<script", "<img", "<b>", "<i>", "<iframe", `"onmouseover`} {
+			if strings.Contains(got, bad) {
+				t.Errorf("markdown(%q) = %q, contains %q", in, got, bad)
+			}
+		}
+	}
+}
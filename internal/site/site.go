@@ -0,0 +1,460 @@
+// Package site writes a catalog document as a static HTML site: a page per
+// schema and table, foreign keys linked in both directions, comments
+// rendered as Markdown and a client-side search. The site is a directory
+// of plain files that works from disk or any web server, without external
+// assets.
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kerem-kaynak/llmshark/internal/export"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+//go:embed assets
+var assets embed.FS
+
+var pages = template.Must(template.New("").Funcs(template.FuncMap{
+	"markdown": markdown,
+	"summary":  summary,
+	"identity": func(kind string) string {
+		return "GENERATED " + strings.ToUpper(strings.ReplaceAll(kind, "_", " ")) + " AS IDENTITY"
+	},
+	"constraintType": func(kind string) string {
+		return strings.ReplaceAll(kind, "_", " ")
+	},
+}).ParseFS(templateFiles, "templates/*.html"))
+
+// manifestName is the file listing what the site consists of, so writing
+// it again removes pages of tables that are gone.
+const manifestName = ".llmshark-site"
+
+// Write writes the site for doc into dir. dir must be missing, empty, or
+// hold a site written before, which is replaced.
+func Write(dir string, doc *export.Document) error {
+	if err := clean(dir); err != nil {
+		return err
+	}
+
+	files, err := newSite(doc).render()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		target := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, f.data, 0o644); err != nil {
+			return err
+		}
+		names = append(names, f.path)
+	}
+	slices.Sort(names)
+	return os.WriteFile(filepath.Join(dir, manifestName), []byte(strings.Join(names, "\n")+"\n"), 0o644)
+}
+
+// clean removes the files of a site written to dir before. Directories
+// holding anything else are left alone.
+func clean(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s is not empty and doesn't hold a site written by llmshark", dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	var subdirs []string
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		name := filepath.FromSlash(scanner.Text())
+		// Never follow a manifest out of the site, by name or through a
+		// linked directory
+		if !filepath.IsLocal(name) || !inside(root, filepath.Join(dir, filepath.Dir(name))) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if sub := filepath.Dir(name); sub != "." && !slices.Contains(subdirs, sub) {
+			subdirs = append(subdirs, sub)
+		}
+	}
+	// Schema directories go once empty, a non-empty one is kept
+	for _, sub := range subdirs {
+		os.Remove(filepath.Join(dir, sub))
+	}
+	return nil
+}
+
+// inside reports whether dir, with its links resolved, is root or below it.
+func inside(root, dir string) bool {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, resolved)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+type file struct {
+	path string // relative to the site root, with forward slashes
+	data []byte
+}
+
+type tableKey struct {
+	schema, table string
+}
+
+// site assigns every schema, table and column of a document its place in
+// the site.
+type site struct {
+	doc        *export.Document
+	schemaDirs map[string]string
+	tablePages map[tableKey]string
+	// columnIDs are the element ids of columns on their table page.
+	columnIDs map[tableKey]map[string]string
+}
+
+func newSite(doc *export.Document) *site {
+	s := &site{
+		doc:        doc,
+		schemaDirs: map[string]string{},
+		tablePages: map[tableKey]string{},
+		columnIDs:  map[tableKey]map[string]string{},
+	}
+
+	dirs := newSlugs()
+	for _, schema := range doc.Schemas {
+		dir := dirs.add(schema.Name)
+		s.schemaDirs[schema.Name] = dir
+
+		// Table pages share the directory with the schema's index.html
+		names := newSlugs("index")
+		for _, table := range schema.Tables {
+			key := tableKey{schema.Name, table.Name}
+			s.tablePages[key] = dir + "/" + names.add(table.Name) + ".html"
+
+			ids := newSlugs()
+			s.columnIDs[key] = map[string]string{}
+			for _, col := range table.Columns {
+				s.columnIDs[key][col.Name] = "column-" + ids.add(col.Name)
+			}
+		}
+	}
+	return s
+}
+
+// slugs turns names into unique file names and ids, which stay distinct on
+// case-insensitive file systems.
+type slugs map[string]bool
+
+func newSlugs(reserved ...string) slugs {
+	s := slugs{}
+	for _, name := range reserved {
+		s[name] = true
+	}
+	return s
+}
+
+func (s slugs) add(name string) string {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name)
+	if base == "" {
+		base = "-"
+	}
+
+	slug := base
+	for n := 2; s[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	s[slug] = true
+	return slug
+}
+
+// link points at a page or a column on one, relative to the site root.
+type link struct {
+	Label string
+	URL   string
+}
+
+// tableLink returns a link to table, and to column on its page if set.
+// Tables outside the site get a label without a URL.
+func (s *site) tableLink(schema, table, column string) link {
+	l := link{Label: schema + "." + table}
+	if column != "" {
+		l.Label += "." + column
+	}
+
+	key := tableKey{schema, table}
+	url, ok := s.tablePages[key]
+	if !ok {
+		return l
+	}
+	l.URL = url
+	if id, ok := s.columnIDs[key][column]; ok {
+		l.URL += "#" + id
+	}
+	return l
+}
+
+// page holds what every page shows.
+type page struct {
+	// Root is the way back to the site root from the page.
+	Root   string
+	Title  string
+	Site   string
+	Header []string
+}
+
+type indexPage struct {
+	page
+	Schemas []schemaEntry
+	Enums   []export.Enum
+}
+
+type schemaEntry struct {
+	Name   string
+	URL    string
+	Tables []tableEntry
+}
+
+type tableEntry struct {
+	Name        string
+	URL         string
+	Description string
+	Columns     int
+}
+
+type schemaPage struct {
+	page
+	Schema schemaEntry
+}
+
+type tablePage struct {
+	page
+	Schema      link
+	Table       export.Table
+	Columns     []columnRow
+	Constraints []constraintRow
+	// ReferencedBy are the foreign keys pointing at the table.
+	ReferencedBy []reference
+}
+
+type columnRow struct {
+	export.Column
+	ID         string
+	Keys       []string
+	References []link
+}
+
+type constraintRow struct {
+	export.Constraint
+	Target *link
+}
+
+type reference struct {
+	Constraint string
+	From       link
+	Columns    []string
+}
+
+// searchEntry is an item of the search index.
+type searchEntry struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+func (s *site) render() ([]file, error) {
+	base := page{Site: "Database schema", Header: s.doc.HeaderLines()}
+	if src := s.doc.Source; src != nil && src.Database != "" {
+		base.Site = src.Database + " schema"
+	}
+
+	var files []file
+	var search []searchEntry
+	add := func(name, tmpl string, data any) error {
+		var b bytes.Buffer
+		if err := pages.ExecuteTemplate(&b, tmpl, data); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		files = append(files, file{name, b.Bytes()})
+		return nil
+	}
+
+	index := indexPage{page: base, Enums: s.doc.Enums}
+	index.Title = base.Site
+
+	for _, schema := range s.doc.Schemas {
+		dir := s.schemaDirs[schema.Name]
+		entry := schemaEntry{Name: schema.Name, URL: dir + "/index.html"}
+		search = append(search, searchEntry{Kind: "schema", Name: schema.Name, URL: entry.URL})
+
+		for _, table := range schema.Tables {
+			key := tableKey{schema.Name, table.Name}
+			entry.Tables = append(entry.Tables, tableEntry{
+				Name:        table.Name,
+				URL:         s.tablePages[key],
+				Description: table.Description,
+				Columns:     len(table.Columns),
+			})
+			search = append(search, searchEntry{
+				Kind:        "table",
+				Name:        schema.Name + "." + table.Name,
+				URL:         s.tablePages[key],
+				Description: summary(table.Description),
+			})
+			for _, col := range table.Columns {
+				search = append(search, searchEntry{
+					Kind:        "column",
+					Name:        schema.Name + "." + table.Name + "." + col.Name,
+					URL:         s.tablePages[key] + "#" + s.columnIDs[key][col.Name],
+					Description: summary(col.Description),
+				})
+			}
+
+			tp := s.tablePage(base, schema.Name, entry.URL, table)
+			if err := add(s.tablePages[key], "table.html", tp); err != nil {
+				return nil, err
+			}
+		}
+
+		sp := schemaPage{page: base, Schema: entry}
+		sp.Root = "../"
+		sp.Title = schema.Name
+		if err := add(entry.URL, "schema.html", sp); err != nil {
+			return nil, err
+		}
+		index.Schemas = append(index.Schemas, entry)
+	}
+
+	if err := add("index.html", "index.html", index); err != nil {
+		return nil, err
+	}
+
+	// The index is a script rather than JSON so that pages opened from
+	// disk can load it
+	data, err := json.Marshal(search)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, file{"search-index.js", []byte("var searchIndex = " + string(data) + ";\n")})
+
+	err = fs.WalkDir(assets, "assets", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := assets.ReadFile(name)
+		files = append(files, file{path.Base(name), data})
+		return err
+	})
+	return files, err
+}
+
+func (s *site) tablePage(base page, schema, schemaURL string, table export.Table) tablePage {
+	key := tableKey{schema, table.Name}
+	p := tablePage{
+		page:   base,
+		Schema: link{Label: schema, URL: schemaURL},
+		Table:  table,
+	}
+	p.Root = "../"
+	p.Title = schema + "." + table.Name
+
+	for _, col := range table.Columns {
+		row := columnRow{Column: col, ID: s.columnIDs[key][col.Name]}
+		if col.PrimaryKey {
+			row.Keys = append(row.Keys, "PK")
+		}
+		if col.Unique {
+			row.Keys = append(row.Keys, "UK")
+		}
+		for _, con := range table.Constraints {
+			if con.References == nil {
+				continue
+			}
+			if i := slices.Index(con.Columns, col.Name); i >= 0 && i < len(con.References.Columns) {
+				ref := con.References
+				row.References = append(row.References, s.tableLink(ref.Schema, ref.Table, ref.Columns[i]))
+			}
+		}
+		if len(row.References) > 0 {
+			row.Keys = append(row.Keys, "FK")
+		}
+		p.Columns = append(p.Columns, row)
+	}
+
+	for _, con := range table.Constraints {
+		row := constraintRow{Constraint: con}
+		if ref := con.References; ref != nil {
+			target := s.tableLink(ref.Schema, ref.Table, "")
+			row.Target = &target
+		}
+		p.Constraints = append(p.Constraints, row)
+	}
+
+	for _, other := range s.doc.Schemas {
+		for _, t := range other.Tables {
+			for _, con := range t.Constraints {
+				ref := con.References
+				if ref == nil || ref.Schema != schema || ref.Table != table.Name {
+					continue
+				}
+				from := s.tableLink(other.Name, t.Name, "")
+				p.ReferencedBy = append(p.ReferencedBy, reference{Constraint: con.Name, From: from, Columns: con.Columns})
+			}
+		}
+	}
+	return p
+}
+
+// summary returns the first line of a comment, for lists and search
+// results.
+func summary(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kerem-kaynak/llmshark/internal/export"
+)
+
+func testDocument() *export.Document {
+	return &export.Document{
+		Version: export.Version,
+		Schemas: []export.Schema{{
+			Name: "shop",
+			Tables: []export.Table{{
+				Name:        "orders",
+				Description: "Customer orders",
+				Columns:     []export.Column{{Name: "id", Type: "integer", PrimaryKey: true}},
+			}},
+		}},
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustExist(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("%s was removed: %v", path, err)
+	}
+}
+
+func TestWriteKeepsFilesItDidNotWrite(t *testing.T) {
+	dir := t.TempDir()
+	if err := Write(dir, testDocument()); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := filepath.Join(dir, "notes.txt")
+	schemaNotes := filepath.Join(dir, "shop", "notes.txt")
+	writeFile(t, notes, "mine")
+	writeFile(t, schemaNotes, "mine")
+
+	// The table is gone the second time, so its page goes too
+	doc := testDocument()
+	doc.Schemas[0].Tables = nil
+	if err := Write(dir, doc); err != nil {
+		t.Fatal(err)
+	}
+	mustExist(t, notes)
+	mustExist(t, schemaNotes)
+	if _, err := os.Stat(filepath.Join(dir, "shop", "orders.html")); !os.IsNotExist(err) {
+		t.Errorf("page of a dropped table was kept: %v", err)
+	}
+}
+
+func TestWriteRefusesForeignDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "index.html"), "someone else's site")
+
+	if err := Write(dir, testDocument()); err == nil {
+		t.Fatal("Write replaced a directory without a manifest")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil || string(data) != "someone else's site" {
+		t.Errorf("index.html = %q, %v; want it untouched", data, err)
+	}
+}
+
+func TestCleanStaysInsideDirectory(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "site")
+	outside := filepath.Join(parent, "outside")
+	for _, d := range []string{dir, outside} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	victims := []string{
+		filepath.Join(parent, "victim.html"),
+		filepath.Join(outside, "victim.html"),
+	}
+	for _, v := range victims {
+		writeFile(t, v, "keep me")
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	manifest := strings.Join([]string{
+		"../victim.html",
+		filepath.ToSlash(victims[1]),
+		"link/victim.html",
+		"shop/../../victim.html",
+	}, "\n")
+	writeFile(t, filepath.Join(dir, manifestName), manifest+"\n")
+
+	if err := clean(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range victims {
+		mustExist(t, v)
+	}
+	mustExist(t, filepath.Join(dir, "link"))
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ConflictPolicy decides what happens when an imported profile has the same
+// name as a local one.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictRename    ConflictPolicy = "rename"
+)
+
+// ConflictPolicies lists the accepted conflict policies.
+var ConflictPolicies = []ConflictPolicy{ConflictSkip, ConflictOverwrite, ConflictRename}
+
+var ErrNotBundle = errors.New("not an LLMShark profile bundle")
+
+// bundleContents is the plaintext of an exported profile bundle.
+type bundleContents struct {
+	Profiles []Profile
+	// Passwords reports whether the profiles were exported with passwords.
+	Passwords bool
+}
+
+// ImportResult lists what happened to each profile in an imported bundle.
+type ImportResult struct {
+	Added       []string
+	Overwritten []string
+	Skipped     []string
+	// Renamed maps bundle profile names to the names they were stored under.
+	Renamed map[string]string
+	// ReferencesRemoved lists the imported profiles whose password
+	// reference was dropped because it runs a command or reads a file.
+	ReferencesRemoved []string
+}
+
+// isUnsafeReference reports whether a password reference from a bundle
+// would run a command or read a file on this machine when connecting.
+// Whoever made the bundle chose it, so it is not imported.
+func isUnsafeReference(password string) bool {
+	return strings.HasPrefix(password, CmdReference) || strings.HasPrefix(password, FileReference)
+}
+
+// ExportProfiles encrypts the named profiles, or all profiles if names is
+// empty, into a bundle protected by passphrase. Passwords are left out
+// unless includePasswords is set.
+func (s *CredentialStore) ExportProfiles(names []string, passphrase string, includePasswords bool) ([]byte, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, ErrPassphraseTooShort
+	}
+
+	profiles, _, err := s.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) > 0 {
+		selected := make([]Profile, 0, len(names))
+		for _, name := range names {
+			i := (&storeContents{Profiles: profiles}).index(name)
+			if i == -1 {
+				return nil, fmt.Errorf("%w: %q", ErrProfileNotFound, name)
+			}
+			selected = append(selected, profiles[i])
+		}
+		profiles = selected
+	}
+
+	// Export paths are local to this machine
+	for i := range profiles {
+		profiles[i].ExportPath = ""
+		if !includePasswords {
+			profiles[i].Credentials = withoutPassword(profiles[i].Credentials)
+		}
+	}
+
+	data, err := json.Marshal(bundleContents{Profiles: profiles, Passwords: includePasswords})
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := newKDFParams()
+	if err != nil {
+		return nil, err
+	}
+	key, err := params.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return sealEnvelope(bundleFormat, key, params, data)
+}
+
+// ImportProfiles decrypts a bundle written by ExportProfiles and merges its
+// profiles into the store, resolving name clashes according to policy.
+func (s *CredentialStore) ImportProfiles(data []byte, passphrase string, policy ConflictPolicy) (*ImportResult, error) {
+	env, err := parseEnvelope(data, bundleFormat)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil || env.KDF == nil {
+		return nil, ErrNotBundle
+	}
+
+	key, err := env.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := env.open(key)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	var bundle bundleContents
+	if err := json.Unmarshal(plaintext, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid profile bundle: %w", err)
+	}
+
+	result := &ImportResult{Renamed: map[string]string{}}
+	err = s.update(func(contents *storeContents) error {
+		for _, p := range bundle.Profiles {
+			p.Name = strings.TrimSpace(p.Name)
+			if p.Name == "" {
+				return ErrProfileName
+			}
+
+			unsafe := isUnsafeReference(p.Credentials.Password)
+			if unsafe {
+				p.Credentials.Password = ""
+			}
+
+			i := contents.index(p.Name)
+			if i == -1 {
+				contents.Profiles = append(contents.Profiles, p)
+				result.Added = append(result.Added, p.Name)
+				if unsafe {
+					result.ReferencesRemoved = append(result.ReferencesRemoved, p.Name)
+				}
+				continue
+			}
+
+			switch policy {
+			case ConflictSkip:
+				result.Skipped = append(result.Skipped, p.Name)
+				continue
+			case ConflictOverwrite:
+				// Keep the local password when the bundle doesn't carry one
+				if (!bundle.Passwords || unsafe) && p.Credentials.Password == "" {
+					p.Credentials.Password = contents.Profiles[i].Credentials.Password
+				}
+				p.ExportPath = contents.Profiles[i].ExportPath
+				contents.Profiles[i] = p
+				result.Overwritten = append(result.Overwritten, p.Name)
+			case ConflictRename:
+				name := contents.freeName(p.Name)
+				result.Renamed[p.Name] = name
+				p.Name = name
+				contents.Profiles = append(contents.Profiles, p)
+			default:
+				return fmt.Errorf("invalid conflict policy %q (expected one of %v)", policy, ConflictPolicies)
+			}
+			if unsafe {
+				result.ReferencesRemoved = append(result.ReferencesRemoved, p.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// freeName returns name with the lowest numeric suffix not yet in use.
+func (c *storeContents) freeName(name string) string {
+	for n := 2; ; n++ {
+		candidate := name + "-" + strconv.Itoa(n)
+		if c.index(candidate) == -1 {
+			return candidate
+		}
+	}
+}
+
+var keywordPassword = regexp.MustCompile(`(^|\s+)password\s*=\s*('(\\.|[^'])*'|\S*)`)
+
+// withoutPassword clears the password of creds, including one embedded in
+// a connection string. Password references are kept, since they don't hold
+// the secret itself.
+func withoutPassword(creds Credentials) Credentials {
+	if !IsPasswordReference(creds.Password) {
+		creds.Password = ""
+	}
+
+	if creds.ConnString == "" {
+		return creds
+	}
+	if u, err := url.Parse(creds.ConnString); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		if u.User != nil {
+			u.User = url.User(u.User.Username())
+		}
+		query := u.Query()
+		if query.Has("password") {
+			query.Del("password")
+			u.RawQuery = query.Encode()
+		}
+		creds.ConnString = u.String()
+		return creds
+	}
+	creds.ConnString = strings.TrimSpace(keywordPassword.ReplaceAllString(creds.ConnString, ""))
+	return creds
+}
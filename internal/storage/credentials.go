@@ -1,113 +1,399 @@
 package storage
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 )
 
+// SSLModes lists the sslmode values accepted by libpq, from least to most strict.
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// QueryModes are the alternatives to pgx's prepared statement cache, for
+// connection poolers such as PgBouncer in transaction mode.
+var QueryModes = []string{"exec", "simple"}
+
+// DefaultSSLMode is used for credentials saved before sslmode was configurable.
+const DefaultSSLMode = "require"
+
+// Password reference prefixes. A password starting with one of these is
+// stored as the reference itself, and the secret package resolves it only
+// when connecting.
+const (
+	EnvReference  = "env:"
+	FileReference = "file:"
+	CmdReference  = "cmd:"
+)
+
+// IsPasswordReference reports whether password refers to a secret held
+// elsewhere rather than being the secret itself.
+func IsPasswordReference(password string) bool {
+	return strings.HasPrefix(password, EnvReference) ||
+		strings.HasPrefix(password, FileReference) ||
+		strings.HasPrefix(password, CmdReference)
+}
+
 type Credentials struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Database string
+	// ConnString, when set, is a postgres:// URI or libpq keyword/value
+	// string used as-is instead of the individual fields below.
+	ConnString string
+
+	// Service names an entry in pg_service.conf. Settings from the service
+	// file apply to every field left empty.
+	Service string
+
+	Host        string
+	Port        string
+	User        string
+	Password    string
+	Database    string
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	// SSH optionally tunnels the connection through a bastion host.
+	SSH SSHTunnel
+
+	// QueryMode is one of QueryModes, or empty to use prepared statements.
+	QueryMode string
+
+	// Connection pool size. Zero uses the defaults.
+	MaxConns int
+	MinConns int
+}
+
+// SSHTunnel describes a bastion host the database is reached through. The
+// tunnel is disabled when Host is empty.
+type SSHTunnel struct {
+	Host string
+	Port string
+	User string
+
+	// KeyFile is the private key used to authenticate. When empty, keys
+	// are taken from the running ssh-agent.
+	KeyFile string
+
+	// KnownHostsFile is checked for the bastion's host key, defaulting
+	// to ~/.ssh/known_hosts.
+	KnownHostsFile        string
+	InsecureIgnoreHostKey bool
+}
+
+func (t *SSHTunnel) Enabled() bool {
+	return t.Host != ""
+}
+
+func (t *SSHTunnel) Validate() error {
+	if !t.Enabled() {
+		if t.User != "" || t.KeyFile != "" || t.Port != "" || t.KnownHostsFile != "" {
+			return errors.New("SSH host is required when using an SSH tunnel")
+		}
+		return nil
+	}
+
+	if t.Port != "" {
+		if _, err := strconv.Atoi(t.Port); err != nil {
+			return fmt.Errorf("invalid SSH port %q", t.Port)
+		}
+	}
+	if t.KeyFile == "" && os.Getenv("SSH_AUTH_SOCK") == "" {
+		return errors.New("no SSH key file given and no ssh-agent is running (SSH_AUTH_SOCK is not set)")
+	}
+	for _, path := range []string{t.KeyFile, t.KnownHostsFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(ExpandHome(path)); err != nil {
+			return fmt.Errorf("SSH tunnel: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Credentials) Validate() error {
+	if err := c.SSH.Validate(); err != nil {
+		return err
+	}
+	if c.QueryMode != "" && !slices.Contains(QueryModes, c.QueryMode) {
+		return fmt.Errorf("invalid query mode %q (expected one of %v)", c.QueryMode, QueryModes)
+	}
+	if c.MaxConns < 0 || c.MinConns < 0 {
+		return errors.New("connection pool sizes cannot be negative")
+	}
+	if c.MaxConns > 0 && c.MinConns > c.MaxConns {
+		return fmt.Errorf("min connections (%d) cannot exceed max connections (%d)", c.MinConns, c.MaxConns)
+	}
+	if c.ConnString != "" {
+		return nil
+	}
+	if c.Port != "" {
+		if _, err := strconv.Atoi(c.Port); err != nil {
+			return fmt.Errorf("invalid port %q", c.Port)
+		}
+	}
+	// Through a tunnel the socket lives on the bastion, so it can't be checked here
+	if c.IsSocket() && !c.SSH.Enabled() {
+		if err := c.checkSocket(); err != nil {
+			return err
+		}
+	}
+	if c.SSLMode != "" && !slices.Contains(SSLModes, c.SSLMode) {
+		return fmt.Errorf("invalid sslmode %q (expected one of %v)", c.SSLMode, SSLModes)
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		return errors.New("client certificate and client key must be set together")
+	}
+	for _, path := range []string{c.SSLRootCert, c.SSLCert, c.SSLKey} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(ExpandHome(path)); err != nil {
+			return fmt.Errorf("certificate file: %w", err)
+		}
+	}
+	return nil
+}
+
+// IsSocket reports whether Host names a Unix domain socket directory, such
+// as /var/run/postgresql, rather than a TCP host.
+func (c *Credentials) IsSocket() bool {
+	return strings.HasPrefix(c.Host, "/")
+}
+
+func (c *Credentials) checkSocket() error {
+	info, err := os.Stat(c.Host)
+	if err != nil {
+		return fmt.Errorf("socket directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("socket directory: %s is not a directory", c.Host)
+	}
+
+	port := c.Port
+	if port == "" {
+		port = "5432"
+	}
+	socket := filepath.Join(c.Host, ".s.PGSQL."+port)
+	if _, err := os.Stat(socket); err != nil {
+		return fmt.Errorf("no PostgreSQL server is listening on %s", socket)
+	}
+	return nil
+}
+
+// ExpandHome replaces a leading "~/" with the user's home directory.
+func ExpandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
 }
 
 type CredentialStore struct {
 	path string
 	key  []byte
+
+	// kdf is set when the key is derived from a passphrase rather than
+	// read from the key file. The store is locked until Unlock is called.
+	kdf *kdfParams
 }
 
 func NewCredentialStore(path string) (*CredentialStore, error) {
-	keyPath := path + ".key"
-	key := make([]byte, 32)
+	s := &CredentialStore{path: path}
+	if err := s.withLock(s.init); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// init picks up the KDF parameters of a passphrase-protected store, or
+// reads the key file otherwise.
+func (s *CredentialStore) init() error {
+	env, err := readEnvelope(s.path)
+	if err != nil {
+		return err
+	}
+
+	if env != nil && env.KDF != nil {
+		s.kdf = env.KDF
+		return nil
+	}
+
+	s.key, err = loadKey(s.path+keySuffix, env)
+	return err
+}
+
+// loadKey reads the key file, creating it if it doesn't exist yet.
+func loadKey(keyPath string, env *envelope) ([]byte, error) {
+	key, err := readPrivateFile(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		if env != nil && env.KeyID != "" {
+			return nil, fmt.Errorf("credentials key file %s is missing", keyPath)
+		}
 
-	if _, err := os.Stat(keyPath); errors.Is(err, os.ErrNotExist) {
 		// Generate new key
+		key = make([]byte, 32)
 		if _, err := io.ReadFull(rand.Reader, key); err != nil {
 			return nil, err
 		}
-		if err := os.WriteFile(keyPath, key, 0600); err != nil {
-			return nil, err
+		err = createKeyFile(keyPath, key)
+		if err == nil {
+			return key, nil
 		}
-	} else {
-		// Read existing key
-		key, err = os.ReadFile(keyPath)
-		if err != nil {
+		if !errors.Is(err, os.ErrExist) {
 			return nil, err
 		}
+
+		// Another instance got there first
+		key, err = readPrivateFile(keyPath)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &CredentialStore{
-		path: path,
-		key:  key,
-	}, nil
+	// A rotation interrupted after re-encrypting leaves the new key pending
+	if env != nil && env.KeyID != "" && keyID(key) != env.KeyID {
+		pendingPath := keyPath + pendingSuffix
+		if pending, err := readPrivateFile(pendingPath); err == nil && keyID(pending) == env.KeyID {
+			if err := os.Rename(pendingPath, keyPath); err != nil {
+				return nil, err
+			}
+			return pending, nil
+		}
+		return nil, errors.New("the key file does not match the credentials file")
+	}
+
+	return key, nil
 }
 
-func (s *CredentialStore) Save(creds *Credentials) error {
-	data, err := json.Marshal(creds)
-	if err != nil {
+// read loads the store contents under the store lock.
+func (s *CredentialStore) read() (*storeContents, error) {
+	var contents *storeContents
+	err := s.withLock(func() error {
+		var err error
+		contents, err = s.load()
 		return err
+	})
+	return contents, err
+}
+
+// update applies fn to the store contents and saves the result, holding
+// the store lock throughout so concurrent updates aren't lost.
+func (s *CredentialStore) update(fn func(contents *storeContents) error) error {
+	return s.withLock(func() error {
+		contents, err := s.load()
+		if err != nil {
+			return err
+		}
+		if err := fn(contents); err != nil {
+			return err
+		}
+		return s.save(contents)
+	})
+}
+
+func (s *CredentialStore) save(contents *storeContents) error {
+	if s.Locked() {
+		return ErrLocked
 	}
 
-	block, err := aes.NewCipher(s.key)
+	data, err := json.Marshal(contents)
 	if err != nil {
 		return err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	sealed, err := sealEnvelope(credentialsFormat, s.key, s.kdf, data)
 	if err != nil {
 		return err
 	}
+	return writeFileAtomic(s.path, sealed)
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return err
+func (s *CredentialStore) load() (*storeContents, error) {
+	if s.Locked() {
+		return nil, ErrLocked
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return os.WriteFile(s.path, ciphertext, 0600)
-}
-
-func (s *CredentialStore) Load() (*Credentials, error) {
-	data, err := os.ReadFile(s.path)
+	data, err := readPrivateFile(s.path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return nil, nil
+			return &storeContents{}, nil
 		}
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(s.key)
+	env, err := parseEnvelope(data, credentialsFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	if env != nil {
+		plaintext, err := env.open(s.key)
+		if err != nil {
+			return nil, err
+		}
+		return decodeContents(plaintext)
+	}
+
+	plaintext, err := openLegacy(s.key, data)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := decodeContents(plaintext)
 	if err != nil {
 		return nil, err
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return nil, errors.New("ciphertext too short")
+	// Upgrade legacy files to the versioned format
+	if err := s.save(contents); err != nil {
+		return nil, err
 	}
+	return contents, nil
+}
+
+// RotateKey re-encrypts the credentials with a newly generated key file.
+// Passphrase-protected stores change their passphrase instead.
+func (s *CredentialStore) RotateKey() error {
+	if s.UsesPassphrase() {
+		return errors.New("credentials are protected by a passphrase; change the passphrase instead")
+	}
+	return s.withLock(s.rotateKey)
+}
 
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+func (s *CredentialStore) rotateKey() error {
+	contents, err := s.load()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var creds Credentials
-	if err := json.Unmarshal(plaintext, &creds); err != nil {
-		return nil, err
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
 	}
 
-	return &creds, nil
+	// The new key is written beside the old one until the credentials are
+	// re-encrypted, so an interrupted rotation can be completed on next start
+	keyPath := s.path + keySuffix
+	pendingPath := keyPath + pendingSuffix
+	if err := writeFileAtomic(pendingPath, key); err != nil {
+		return err
+	}
+
+	oldKey := s.key
+	s.key = key
+	if err := s.save(contents); err != nil {
+		s.key = oldKey
+		os.Remove(pendingPath)
+		return err
+	}
+	return os.Rename(pendingPath, keyPath)
 }
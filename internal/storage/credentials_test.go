@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestLegacyFileUpgradesToEnvelope(t *testing.T) {
+	s := newTestStore(t)
+	key := newKey(t)
+	if err := os.WriteFile(s.path+keySuffix, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unversioned format is the nonce followed by the ciphertext
+	plaintext, err := json.Marshal(storeContents{Profiles: []Profile{{Name: "prod", Credentials: Credentials{Password: "secret"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.path, gcm.Seal(nonce, nonce, plaintext, nil), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reopen(t, s)
+	if got := profilePassword(t, s, "prod"); got != "secret" {
+		t.Fatalf("password from the legacy file = %q, want %q", got, "secret")
+	}
+
+	env, err := readEnvelope(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env == nil || env.Version != envelopeVersion || env.KeyID != keyID(key) {
+		t.Fatalf("credentials file after loading = %+v, want an envelope sealed with the key file", env)
+	}
+}
+
+func TestTamperedHeaderFailsToAuthenticate(t *testing.T) {
+	key := newKey(t)
+	sealed, err := sealEnvelope(credentialsFormat, key, nil, []byte(`{"Profiles":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampers := map[string]func(env *envelope){
+		"version": func(env *envelope) { env.Version = 0 },
+		"key id":  func(env *envelope) { env.KeyID = keyID(newKey(t)) },
+		"kdf":     func(env *envelope) { env.KDF = &kdfParams{Salt: []byte("salt"), N: 2, R: 1, P: 1} },
+	}
+	for name, tamper := range tampers {
+		env, err := parseEnvelope(sealed, credentialsFormat)
+		if err != nil || env == nil {
+			t.Fatalf("parseEnvelope: %v", err)
+		}
+		if _, err := env.open(key); err != nil {
+			t.Fatalf("untouched envelope: %v", err)
+		}
+
+		tamper(env)
+		if _, err := env.open(key); !errors.Is(err, errDecrypt) {
+			t.Errorf("envelope with a changed %s opened: %v", name, err)
+		}
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	s := newTestStore(t)
+	saveProfiles(t, s, Profile{Name: "prod", Credentials: Credentials{Password: "secret"}})
+	oldKey := bytes.Clone(s.key)
+
+	if err := s.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(s.key, oldKey) {
+		t.Fatal("RotateKey kept the old key")
+	}
+	if got := profilePassword(t, reopen(t, s), "prod"); got != "secret" {
+		t.Errorf("password after rotating = %q, want %q", got, "secret")
+	}
+}
+
+func TestRotateKeyRecoversPendingKey(t *testing.T) {
+	s := newTestStore(t)
+	saveProfiles(t, s, Profile{Name: "prod", Credentials: Credentials{Password: "secret"}})
+
+	// Interrupt a rotation after re-encrypting, before the new key
+	// replaces the old one
+	contents, err := s.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := newKey(t)
+	if err := writeFileAtomic(s.path+keySuffix+pendingSuffix, pending); err != nil {
+		t.Fatal(err)
+	}
+	s.key = pending
+	if err := s.save(contents); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reopen(t, s)
+	if got := profilePassword(t, s, "prod"); got != "secret" {
+		t.Fatalf("password after recovering = %q, want %q", got, "secret")
+	}
+	key, err := os.ReadFile(s.path + keySuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, pending) {
+		t.Error("the pending key didn't replace the key file")
+	}
+	if _, err := os.Stat(s.path + keySuffix + pendingSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("pending key file still exists: %v", err)
+	}
+}
+
+func TestKeyFileMismatch(t *testing.T) {
+	s := newTestStore(t)
+	saveProfiles(t, s, Profile{Name: "prod", Credentials: Credentials{Password: "secret"}})
+
+	if err := os.WriteFile(s.path+keySuffix, newKey(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewCredentialStore(s.path)
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("NewCredentialStore with another key file = %v, want a mismatch error", err)
+	}
+}
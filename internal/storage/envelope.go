@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Formats identifying what an envelope holds.
+const (
+	credentialsFormat = "llmshark-credentials"
+	bundleFormat      = "llmshark-profiles"
+)
+
+const envelopeVersion = 1
+
+const (
+	keySuffix     = ".key"
+	pendingSuffix = ".new"
+)
+
+// errDecrypt is returned when the ciphertext doesn't open with the key,
+// which usually means the key or passphrase is wrong.
+var errDecrypt = errors.New("failed to decrypt credentials")
+
+// envelope is the on-disk format of the credentials file and of exported
+// profile bundles. Everything but the ciphertext forms a header that is
+// authenticated along with it.
+type envelope struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+
+	// KDF holds the key derivation parameters of passphrase-protected
+	// stores. It is nil when the key is read from the key file.
+	KDF *kdfParams `json:"kdf,omitempty"`
+
+	// KeyID identifies the key file a key-file store was sealed with.
+	KeyID string `json:"key_id,omitempty"`
+
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// keyID returns a short fingerprint of key, used to match a credentials
+// file with the key it was sealed with.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// header returns the authenticated data binding the envelope fields to
+// the ciphertext.
+func (e *envelope) header() ([]byte, error) {
+	return json.Marshal(struct {
+		Format  string     `json:"format"`
+		Version int        `json:"version"`
+		KDF     *kdfParams `json:"kdf,omitempty"`
+		KeyID   string     `json:"key_id,omitempty"`
+	}{e.Format, e.Version, e.KDF, e.KeyID})
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealEnvelope encrypts plaintext with key into an encoded envelope of the
+// given format. kdf is nil for key-file stores.
+func sealEnvelope(format string, key []byte, kdf *kdfParams, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{
+		Format:  format,
+		Version: envelopeVersion,
+		KDF:     kdf,
+		Nonce:   make([]byte, gcm.NonceSize()),
+	}
+	if kdf == nil {
+		env.KeyID = keyID(key)
+	}
+	if _, err := io.ReadFull(rand.Reader, env.Nonce); err != nil {
+		return nil, err
+	}
+
+	header, err := env.header()
+	if err != nil {
+		return nil, err
+	}
+	env.Ciphertext = gcm.Seal(nil, env.Nonce, plaintext, header)
+
+	return json.MarshalIndent(env, "", "  ")
+}
+
+func (e *envelope) open(key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := e.header()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, e.Nonce, e.Ciphertext, header)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plaintext, nil
+}
+
+// parseEnvelope decodes an envelope of the given format. Credentials files
+// written before the versioned format are raw ciphertext, for which it
+// returns nil.
+func parseEnvelope(data []byte, format string) (*envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != format {
+		return nil, nil
+	}
+
+	if env.Version > envelopeVersion {
+		return nil, fmt.Errorf("%s version %d was written by a newer version of LLMShark", env.Format, env.Version)
+	}
+	return &env, nil
+}
+
+// readEnvelope reads the envelope at path, returning nil if the file
+// doesn't exist or predates the versioned format.
+func readEnvelope(path string) (*envelope, error) {
+	data, err := readPrivateFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseEnvelope(data, credentialsFormat)
+}
+
+// openLegacy decrypts the unversioned format: the AES-GCM nonce followed by
+// the ciphertext, with no authenticated header.
+func openLegacy(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plaintext, nil
+}
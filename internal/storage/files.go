@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const lockSuffix = ".lock"
+
+// withLock runs fn while holding an exclusive lock on the store, so that
+// several LLMShark instances can't interleave their reads and writes.
+func (s *CredentialStore) withLock(fn func() error) error {
+	f, err := os.OpenFile(s.path+lockSuffix, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("failed to lock credential store: %w", err)
+	}
+	defer unlockFile(f)
+
+	return fn()
+}
+
+// readPrivateFile reads a key or credentials file, refusing to use it if
+// other users could read it too.
+func readPrivateFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPermissions(path, info); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+func checkPermissions(path string, info os.FileInfo) error {
+	// Windows doesn't map its ACLs onto Unix permission bits
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if mode := info.Mode().Perm(); mode&0077 != 0 {
+		return fmt.Errorf("%s is accessible by other users (mode %04o); restrict it with: chmod 600 %s", path, mode, path)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data, writing to a temporary file in
+// the same directory first so a crash never leaves a partial file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// createKeyFile writes a new key to path, failing with os.ErrExist if the
+// file already exists rather than overwriting another instance's key.
+func createKeyFile(path string, key []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(key)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// removeIfExists removes path, ignoring a file that is already gone.
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
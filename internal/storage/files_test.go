@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentUpdatesAreNotLost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	// Every instance opens the store itself, racing to create the key file
+	const instances = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, instances)
+	for i := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := NewCredentialStore(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- s.SaveProfile("", Profile{Name: "profile-" + strconv.Itoa(i), Credentials: Credentials{Host: "db"}})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewCredentialStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles, _, err := s.Profiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != instances {
+		t.Fatalf("store holds %d profiles after %d concurrent saves", len(profiles), instances)
+	}
+}
+
+func TestRejectsFilesOthersCanRead(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows permissions aren't mapped onto mode bits")
+	}
+
+	for _, suffix := range []string{"", keySuffix} {
+		s := newTestStore(t)
+		saveProfiles(t, s, Profile{Name: "prod", Credentials: Credentials{Host: "db"}})
+		if err := os.Chmod(s.path+suffix, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := NewCredentialStore(s.path)
+		if err == nil || !strings.Contains(err.Error(), "accessible by other users") {
+			t.Errorf("opening a store with a 0644 %q file = %v, want a permissions error", "credentials"+suffix, err)
+		}
+	}
+}
+
+func TestWriteFileAtomicIsPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows permissions aren't mapped onto mode bits")
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := writeFileAtomic(path, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("mode = %04o, want 0600", mode)
+	}
+	if _, err := readPrivateFile(path); err != nil {
+		t.Errorf("readPrivateFile: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// MinPassphraseLength is the shortest passphrase accepted when enabling
+// passphrase protection.
+const MinPassphraseLength = 8
+
+var (
+	ErrLocked             = errors.New("credential store is locked")
+	ErrWrongPassphrase    = errors.New("incorrect passphrase")
+	ErrPassphraseTooShort = fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+)
+
+// kdfParams are the scrypt parameters used to derive the store key.
+type kdfParams struct {
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+func newKDFParams() (*kdfParams, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return &kdfParams{Salt: salt, N: 1 << 15, R: 8, P: 1}, nil
+}
+
+// Limits on the KDF parameters read from a file. Bundles come from other
+// people, and scrypt needs 128·N·R bytes of memory, so larger values could
+// exhaust the machine before the passphrase is even checked.
+const (
+	maxKDFCost            = 1 << 20
+	maxKDFBlockSize       = 32
+	maxKDFParallelization = 16
+)
+
+func (p *kdfParams) validate() error {
+	if p.N < 2 || p.N > maxKDFCost || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("invalid KDF cost %d (expected a power of two up to %d)", p.N, maxKDFCost)
+	}
+	if p.R < 1 || p.R > maxKDFBlockSize {
+		return fmt.Errorf("invalid KDF block size %d (expected 1 to %d)", p.R, maxKDFBlockSize)
+	}
+	if p.P < 1 || p.P > maxKDFParallelization {
+		return fmt.Errorf("invalid KDF parallelization %d (expected 1 to %d)", p.P, maxKDFParallelization)
+	}
+	if len(p.Salt) == 0 {
+		return errors.New("KDF salt is missing")
+	}
+	return nil
+}
+
+func (p *kdfParams) deriveKey(passphrase string) ([]byte, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return scrypt.Key([]byte(passphrase), p.Salt, p.N, p.R, p.P, 32)
+}
+
+// UsesPassphrase reports whether the store key is derived from a passphrase.
+func (s *CredentialStore) UsesPassphrase() bool {
+	return s.kdf != nil
+}
+
+// Locked reports whether the store is waiting for its passphrase.
+func (s *CredentialStore) Locked() bool {
+	return s.key == nil
+}
+
+// Unlock derives the store key from passphrase, checking it against the
+// stored credentials.
+func (s *CredentialStore) Unlock(passphrase string) error {
+	if !s.UsesPassphrase() {
+		return nil
+	}
+
+	key, err := s.kdf.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return s.withLock(func() error {
+		return s.unlock(key)
+	})
+}
+
+func (s *CredentialStore) unlock(key []byte) error {
+	data, err := readPrivateFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.key = key
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	env, err := parseEnvelope(data, credentialsFormat)
+	if err != nil {
+		return err
+	}
+	if env == nil {
+		return ErrWrongPassphrase
+	}
+	if _, err := env.open(key); err != nil {
+		return ErrWrongPassphrase
+	}
+
+	s.key = key
+
+	// Remove a key file left behind by an interrupted migration
+	return removeIfExists(s.path + keySuffix)
+}
+
+// EnablePassphrase migrates a key-file store to a key derived from
+// passphrase, re-encrypting the credentials and removing the key file.
+func (s *CredentialStore) EnablePassphrase(passphrase string) error {
+	if s.UsesPassphrase() {
+		return errors.New("credentials are already protected by a passphrase")
+	}
+	return s.setPassphrase(passphrase)
+}
+
+// ChangePassphrase re-encrypts the credentials with a key derived from a new
+// passphrase and a fresh salt. The store must be unlocked.
+func (s *CredentialStore) ChangePassphrase(passphrase string) error {
+	if !s.UsesPassphrase() {
+		return errors.New("credentials are not protected by a passphrase")
+	}
+	return s.setPassphrase(passphrase)
+}
+
+func (s *CredentialStore) setPassphrase(passphrase string) error {
+	if len(passphrase) < MinPassphraseLength {
+		return ErrPassphraseTooShort
+	}
+
+	params, err := newKDFParams()
+	if err != nil {
+		return err
+	}
+	key, err := params.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return s.withLock(func() error {
+		return s.rekey(key, params)
+	})
+}
+
+func (s *CredentialStore) rekey(key []byte, params *kdfParams) error {
+	contents, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	// The KDF parameters travel in the envelope, so switching keys is a
+	// single write of the credentials file
+	oldKey, oldKDF := s.key, s.kdf
+	s.key, s.kdf = key, params
+	if err := s.save(contents); err != nil {
+		s.key, s.kdf = oldKey, oldKDF
+		return err
+	}
+
+	return removeIfExists(s.path + keySuffix)
+}
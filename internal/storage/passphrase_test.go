@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDeriveKeyRejectsExpensiveParams(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	tests := []struct {
+		name   string
+		params kdfParams
+	}{
+		{"huge cost", kdfParams{Salt: salt, N: 1 << 30, R: 8, P: 1}},
+		{"cost not a power of two", kdfParams{Salt: salt, N: 3 << 10, R: 8, P: 1}},
+		{"huge block size", kdfParams{Salt: salt, N: 1 << 15, R: 1 << 20, P: 1}},
+		{"huge parallelization", kdfParams{Salt: salt, N: 1 << 15, R: 8, P: 1 << 10}},
+		{"zero block size", kdfParams{Salt: salt, N: 1 << 15, R: 0, P: 1}},
+		{"no salt", kdfParams{N: 1 << 15, R: 8, P: 1}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.params.deriveKey("passphrase"); err == nil {
+			t.Errorf("%s: deriveKey succeeded", tt.name)
+		}
+	}
+}
+
+func TestImportProfilesRejectsExpensiveKDF(t *testing.T) {
+	params := &kdfParams{Salt: []byte("0123456789abcdef"), N: 1 << 30, R: 8, P: 1}
+	bundle, err := sealEnvelope(bundleFormat, make([]byte, 32), params, []byte(`{"Profiles":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newTestStore(t).ImportProfiles(bundle, "bundle passphrase", ConflictSkip); err == nil {
+		t.Fatal("ImportProfiles accepted a bundle demanding 128 GiB for its KDF")
+	}
+}
+
+// reopen opens the store at the path of s again, as the next start does.
+func reopen(t *testing.T, s *CredentialStore) *CredentialStore {
+	t.Helper()
+	reopened, err := NewCredentialStore(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reopened
+}
+
+func TestEnablePassphrase(t *testing.T) {
+	s := newTestStore(t)
+	saveProfiles(t, s, Profile{Name: "prod", Credentials: Credentials{Host: "db", Password: "secret"}})
+
+	if err := s.EnablePassphrase("short"); !errors.Is(err, ErrPassphraseTooShort) {
+		t.Fatalf("EnablePassphrase with a short passphrase = %v, want ErrPassphraseTooShort", err)
+	}
+	if err := s.EnablePassphrase("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.path + keySuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("key file still exists after enabling a passphrase: %v", err)
+	}
+	if err := s.EnablePassphrase("correct horse"); err == nil {
+		t.Error("EnablePassphrase succeeded on a store that already uses one")
+	}
+
+	s = reopen(t, s)
+	if !s.UsesPassphrase() || !s.Locked() {
+		t.Fatalf("reopened store: UsesPassphrase = %v, Locked = %v, want both true", s.UsesPassphrase(), s.Locked())
+	}
+	if _, _, err := s.Profiles(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Profiles on a locked store = %v, want ErrLocked", err)
+	}
+	if err := s.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if got := profilePassword(t, s, "prod"); got != "secret" {
+		t.Errorf("password after unlocking = %q, want %q", got, "secret")
+	}
+}
+
+func TestUnlockWrongPassphrase(t *testing.T) {
+	s := newTestStore(t)
+	saveProfiles(t, s, Profile{Name: "prod", Credentials: Credentials{Host: "db"}})
+	if err := s.EnablePassphrase("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reopen(t, s)
+	if err := s.Unlock("battery staple"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Unlock with a wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+	if !s.Locked() {
+		t.Error("store is unlocked after a wrong passphrase")
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	s := newTestStore(t)
+	saveProfiles(t, s, Profile{Name: "prod", Credentials: Credentials{Host: "db", Password: "secret"}})
+	if err := s.ChangePassphrase("new passphrase"); err == nil {
+		t.Fatal("ChangePassphrase succeeded on a store without a passphrase")
+	}
+	if err := s.EnablePassphrase("old passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ChangePassphrase("new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	s = reopen(t, s)
+	if err := s.Unlock("old passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Unlock with the old passphrase = %v, want ErrWrongPassphrase", err)
+	}
+	if err := s.Unlock("new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if got := profilePassword(t, s, "prod"); got != "secret" {
+		t.Errorf("password after changing the passphrase = %q, want %q", got, "secret")
+	}
+}
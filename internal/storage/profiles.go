@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrProfileExists   = errors.New("a profile with this name already exists")
+	ErrProfileName     = errors.New("profile name cannot be empty")
+)
+
+// DefaultProfileName is given to credentials stored before profiles existed.
+const DefaultProfileName = "default"
+
+// Profile is a named set of connection details together with the
+// settings that apply whenever it is used.
+type Profile struct {
+	Name        string
+	Credentials Credentials
+	Filters
+
+	// ExportPath is the file the selection was last saved to.
+	ExportPath string `json:",omitempty"`
+}
+
+// Filters are the schema and table patterns applied when loading the
+// catalog. Empty lists fall back to the application defaults.
+type Filters struct {
+	IncludeSchemas []string
+	ExcludeSchemas []string
+	IncludeTables  []string
+	ExcludeTables  []string
+}
+
+// storeContents is the plaintext held in the encrypted credentials file.
+type storeContents struct {
+	Profiles []Profile
+	// LastUsed is the name of the profile connected to most recently.
+	LastUsed string
+}
+
+// decodeContents parses the decrypted file, converting the single
+// Credentials object written by older versions into a default profile.
+func decodeContents(plaintext []byte) (*storeContents, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(plaintext, &probe); err != nil {
+		return nil, err
+	}
+
+	if _, ok := probe["Profiles"]; ok {
+		var contents storeContents
+		if err := json.Unmarshal(plaintext, &contents); err != nil {
+			return nil, err
+		}
+		return &contents, nil
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, err
+	}
+	if creds.ConnString == "" && creds.SSLMode == "" {
+		creds.SSLMode = DefaultSSLMode
+	}
+
+	return &storeContents{
+		Profiles: []Profile{{Name: DefaultProfileName, Credentials: creds}},
+		LastUsed: DefaultProfileName,
+	}, nil
+}
+
+func (c *storeContents) index(name string) int {
+	return slices.IndexFunc(c.Profiles, func(p Profile) bool {
+		return p.Name == name
+	})
+}
+
+// Profiles returns all stored profiles sorted by name, along with the name
+// of the most recently used one.
+func (s *CredentialStore) Profiles() ([]Profile, string, error) {
+	contents, err := s.read()
+	if err != nil {
+		return nil, "", err
+	}
+
+	profiles := slices.Clone(contents.Profiles)
+	slices.SortFunc(profiles, func(a, b Profile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return profiles, contents.LastUsed, nil
+}
+
+// SaveProfile stores p. If previousName is empty p is added as a new
+// profile, otherwise it replaces the profile called previousName.
+func (s *CredentialStore) SaveProfile(previousName string, p Profile) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return ErrProfileName
+	}
+
+	return s.update(func(contents *storeContents) error {
+		existing := contents.index(p.Name)
+		if existing != -1 && p.Name != previousName {
+			return fmt.Errorf("%w: %q", ErrProfileExists, p.Name)
+		}
+
+		if previousName == "" {
+			contents.Profiles = append(contents.Profiles, p)
+			return nil
+		}
+
+		i := contents.index(previousName)
+		if i == -1 {
+			return fmt.Errorf("%w: %q", ErrProfileNotFound, previousName)
+		}
+		// The form doesn't edit the export path, keep the stored one
+		if p.ExportPath == "" {
+			p.ExportPath = contents.Profiles[i].ExportPath
+		}
+		contents.Profiles[i] = p
+		if contents.LastUsed == previousName {
+			contents.LastUsed = p.Name
+		}
+		return nil
+	})
+}
+
+func (s *CredentialStore) RenameProfile(oldName, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return ErrProfileName
+	}
+
+	return s.update(func(contents *storeContents) error {
+		i := contents.index(oldName)
+		if i == -1 {
+			return fmt.Errorf("%w: %q", ErrProfileNotFound, oldName)
+		}
+		if newName == oldName {
+			return nil
+		}
+		if contents.index(newName) != -1 {
+			return fmt.Errorf("%w: %q", ErrProfileExists, newName)
+		}
+
+		contents.Profiles[i].Name = newName
+		if contents.LastUsed == oldName {
+			contents.LastUsed = newName
+		}
+		return nil
+	})
+}
+
+func (s *CredentialStore) DeleteProfile(name string) error {
+	return s.update(func(contents *storeContents) error {
+		i := contents.index(name)
+		if i == -1 {
+			return fmt.Errorf("%w: %q", ErrProfileNotFound, name)
+		}
+
+		contents.Profiles = slices.Delete(contents.Profiles, i, i+1)
+		if contents.LastUsed == name {
+			contents.LastUsed = ""
+		}
+		return nil
+	})
+}
+
+// SetExportPath remembers path as the file the selection of profile name
+// was last saved to.
+func (s *CredentialStore) SetExportPath(name, path string) error {
+	return s.update(func(contents *storeContents) error {
+		i := contents.index(name)
+		if i == -1 {
+			return fmt.Errorf("%w: %q", ErrProfileNotFound, name)
+		}
+		contents.Profiles[i].ExportPath = path
+		return nil
+	})
+}
+
+// MarkUsed records name as the most recently used profile so the picker
+// can preselect it next time.
+func (s *CredentialStore) MarkUsed(name string) error {
+	return s.update(func(contents *storeContents) error {
+		contents.LastUsed = name
+		return nil
+	})
+}
@@ -0,0 +1,237 @@
+// Package tokens counts the tokens language model tokenizers use for a
+// text. It encodes with cl100k_base, the byte-pair encoding of GPT-4,
+// whose rank table is embedded, so counting needs no network access. Other
+// models' tokenizers land close to it.
+package tokens
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// cl100kBase is the rank table published with OpenAI's tiktoken: a base64
+// token and its rank per line, lower ranks merging first. It is MIT
+// licensed, see NOTICE and LICENSE-tiktoken.
+//
+//go:embed cl100k_base.tiktoken
+var cl100kBase []byte
+
+var (
+	ranks     map[string]int
+	ranksOnce sync.Once
+)
+
+// loadRanks parses the rank table, once and only when first needed.
+func loadRanks() map[string]int {
+	ranksOnce.Do(func() {
+		ranks = make(map[string]int, 100256)
+		scanner := bufio.NewScanner(bytes.NewReader(cl100kBase))
+		for scanner.Scan() {
+			token, rank, ok := bytes.Cut(scanner.Bytes(), []byte(" "))
+			if !ok {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(string(token))
+			if err != nil {
+				panic("tokens: invalid rank table: " + err.Error())
+			}
+			n, err := strconv.Atoi(string(rank))
+			if err != nil {
+				panic("tokens: invalid rank table: " + err.Error())
+			}
+			ranks[string(decoded)] = n
+		}
+	})
+	return ranks
+}
+
+// Count returns the number of tokens s encodes to with cl100k_base. s is
+// split into pieces the way the encoder splits it, and as merges never
+// cross pieces, each piece is merged on its own.
+func Count(s string) int {
+	ranks := loadRanks()
+	count := 0
+	for len(s) > 0 {
+		n := nextPiece(s)
+		count += merge(s[:n], ranks)
+		s = s[n:]
+	}
+	return count
+}
+
+// noRank marks a pair of tokens that never merges.
+const noRank = math.MaxInt
+
+// merge returns the number of tokens piece encodes to. Starting from its
+// bytes, the adjacent pair with the lowest rank is merged until no pair is
+// in the table, as tiktoken does.
+func merge(piece string, ranks map[string]int) int {
+	if _, ok := ranks[piece]; ok {
+		return 1
+	}
+
+	// parts holds the start of every token and the rank of merging it with
+	// the next one, followed by the end of the piece
+	type part struct{ start, rank int }
+	parts := make([]part, len(piece)+1)
+	for i := range parts {
+		parts[i] = part{i, noRank}
+	}
+	pairRank := func(i int) int {
+		if i+2 < len(parts) {
+			if r, ok := ranks[piece[parts[i].start:parts[i+2].start]]; ok {
+				return r
+			}
+		}
+		return noRank
+	}
+	for i := range len(parts) - 2 {
+		parts[i].rank = pairRank(i)
+	}
+
+	for {
+		lowest, at := noRank, -1
+		for i, p := range parts[:len(parts)-1] {
+			if p.rank < lowest {
+				lowest, at = p.rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		parts = slices.Delete(parts, at+1, at+2)
+		parts[at].rank = pairRank(at)
+		if at > 0 {
+			parts[at-1].rank = pairRank(at - 1)
+		}
+	}
+	return len(parts) - 1
+}
+
+// nextPiece returns the length in bytes of the piece at the start of s. It
+// follows the cl100k pre-tokenization pattern, taking the first of these
+// alternatives that matches:
+//
+//	'(s|t|re|ve|m|ll|d)
+//	[^\r\n\pL\pN]?\pL+
+//	\pN{1,3}
+//	 ?[^\s\pL\pN]+[\r\n]*
+//	\s*[\r\n]+
+//	\s+(?!\S)
+//	\s+
+func nextPiece(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+	rest := s[size:]
+	next, _ := utf8.DecodeRuneInString(rest)
+
+	switch {
+	case r == '\'' && contraction(rest) > 0:
+		return size + contraction(rest)
+
+	case unicode.IsLetter(r):
+		return letters(s)
+
+	case r != '\r' && r != '\n' && !unicode.IsNumber(r) && unicode.IsLetter(next):
+		return size + letters(rest)
+
+	case unicode.IsNumber(r):
+		return digits(s)
+
+	case isPunctuation(r) || (r == ' ' && isPunctuation(next)):
+		n := 0
+		if r == ' ' {
+			n = size
+		}
+		for _, p := range s[n:] {
+			if !isPunctuation(p) {
+				break
+			}
+			n += utf8.RuneLen(p)
+		}
+		for n < len(s) && (s[n] == '\r' || s[n] == '\n') {
+			n++
+		}
+		return n
+	}
+
+	// Whitespace: a run ending in line breaks, a run leaving its last space
+	// to the following word, or the whole run
+	end, lastBreak := 0, -1
+	for i, w := range s {
+		if !unicode.IsSpace(w) {
+			break
+		}
+		end = i + utf8.RuneLen(w)
+		if w == '\r' || w == '\n' {
+			lastBreak = end
+		}
+	}
+	switch {
+	case end == 0:
+		// Invalid UTF-8, taken a byte at a time
+		return size
+	case lastBreak > 0:
+		end = lastBreak
+	case end < len(s) && end > size:
+		_, last := utf8.DecodeLastRuneInString(s[:end])
+		end -= last
+	}
+	return end
+}
+
+// contraction returns the length of the English contraction suffix at the
+// start of s, after an apostrophe, or 0.
+func contraction(s string) int {
+	for _, suffix := range []string{"re", "ve", "ll", "s", "t", "m", "d"} {
+		if len(s) >= len(suffix) && equalFold(s[:len(suffix)], suffix) {
+			return len(suffix)
+		}
+	}
+	return 0
+}
+
+func equalFold(a, b string) bool {
+	for i := range len(a) {
+		if unicode.ToLower(rune(a[i])) != rune(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// letters returns the length of the run of letters at the start of s.
+func letters(s string) int {
+	n := 0
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			break
+		}
+		n += utf8.RuneLen(r)
+	}
+	return n
+}
+
+// digits returns the length of the up to three digits at the start of s.
+func digits(s string) int {
+	n, count := 0, 0
+	for _, r := range s {
+		if !unicode.IsNumber(r) || count == 3 {
+			break
+		}
+		n += utf8.RuneLen(r)
+		count++
+	}
+	return n
+}
+
+func isPunctuation(r rune) bool {
+	return r != utf8.RuneError && !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
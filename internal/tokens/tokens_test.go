@@ -0,0 +1,28 @@
+package tokens
+
+import "testing"
+
+// The expected counts are those of OpenAI's tiktoken for cl100k_base.
+func TestCount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"CREATE TABLE users (id integer PRIMARY KEY);", 9},
+		{"SELECT id, name FROM public.users WHERE email = 'a@b.co';", 16},
+		{"orders(id int PK AUTO, user_id int NN FK→users.id)", 15},
+		{"## Table `public.orders`\n\n| Column | Type |\n|---|---|\n| id | integer |\n", 21},
+		{"Grüße aus Köln", 6},
+		{"日本語のテキスト", 8},
+		{"I'm sure they'LL say 1234567", 11},
+		{"    indented\n\n\nlines   ", 6},
+		{"🦈", 3},
+	}
+	for _, tt := range tests {
+		if got := Count(tt.in); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
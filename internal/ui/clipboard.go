@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/kerem-kaynak/llmshark/internal/config"
+)
+
+// copyToClipboard puts text on the clipboard as the export.clipboard setting
+// asks, reporting whether it was sent to the terminal with OSC 52 rather
+// than to the system clipboard.
+func (m *model) copyToClipboard(text string) (bool, error) {
+	mode := m.config.Export.Clipboard
+	if mode == config.ClipboardAuto && remoteSession() {
+		mode = config.ClipboardOSC52
+	}
+
+	if mode != config.ClipboardOSC52 {
+		err := clipboard.WriteAll(text)
+		if err == nil || mode == config.ClipboardSystem {
+			return false, err
+		}
+	}
+	return true, writeOSC52(m.terminal, text)
+}
+
+// remoteSession reports whether we run over SSH, where the system clipboard
+// is the server's rather than the user's.
+func remoteSession() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// screenChunk is the longest string GNU screen passes through in one
+// device control string.
+const screenChunk = 768
+
+// writeOSC52 asks the terminal to set its clipboard to text. Inside tmux and
+// screen the sequence is wrapped so the multiplexer hands it on to the
+// terminal; tmux also needs set-clipboard or allow-passthrough enabled.
+func writeOSC52(w io.Writer, text string) error {
+	if w == nil {
+		return errors.New("no terminal to send the clipboard sequence to")
+	}
+
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		var b strings.Builder
+		for len(seq) > 0 {
+			n := min(len(seq), screenChunk)
+			b.WriteString("\x1bP" + seq[:n] + "\x1b\\")
+			seq = seq[n:]
+		}
+		seq = b.String()
+	}
+
+	_, err := io.WriteString(w, seq)
+	return err
+}
@@ -4,12 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 
-	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/kerem-kaynak/llmshark/internal/markdown"
+	"github.com/kerem-kaynak/llmshark/internal/config"
+	"github.com/kerem-kaynak/llmshark/internal/export"
 	"github.com/kerem-kaynak/llmshark/internal/postgres"
+	"github.com/kerem-kaynak/llmshark/internal/secret"
 	"github.com/kerem-kaynak/llmshark/internal/storage"
+	"github.com/kerem-kaynak/llmshark/internal/tokens"
 )
 
 type errMsg struct {
@@ -22,6 +30,17 @@ type credsMsg struct {
 
 type noCredsMsg struct{}
 
+type profilesMsg struct {
+	profiles []storage.Profile
+	lastUsed string
+}
+
+// filterErrMsg reports a filter the server rejected while loading the
+// catalog, which leaves the connection usable.
+type filterErrMsg struct {
+	error
+}
+
 type connectedMsg struct {
 	client  *postgres.Client
 	schemas []postgres.Schema
@@ -55,46 +74,58 @@ func (m model) updateCredentials(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		switch msg.String() {
 		case "enter":
-			m.schemas = nil
-			m.client = nil
-
-			creds := &storage.Credentials{
-				Host:     m.inputs[0].Value(),
-				Port:     m.inputs[1].Value(),
-				Database: m.inputs[2].Value(),
-				User:     m.inputs[3].Value(),
-				Password: m.inputs[4].Value(),
+			profile, err := m.formProfile()
+			if err != nil {
+				m.err = err
+				return m, nil
 			}
 
-			if err := m.credStore.Save(creds); err != nil {
+			if err := m.credStore.SaveProfile(m.editingProfile, profile); err != nil {
 				m.err = err
 				return m, nil
 			}
+			m.editingProfile = profile.Name
 
-			m.state = stateLoading
-			return m, connectToDB(creds)
+			return m, m.connectProfile(profile)
+
+		case "ctrl+t":
+			m.connMode = !m.connMode
+			visible := m.visibleInputs()
+			if !slices.Contains(visible, m.activeInput) {
+				m.focusInput(visible[1])
+			}
+			return m, nil
 
 		case "esc":
-			m.state = stateExplorer
+			if m.client != nil {
+				m.state = stateExplorer
+				return m, nil
+			}
+			if err := m.reloadProfiles(); err != nil {
+				m.err = err
+				return m, nil
+			}
+			if len(m.profiles) > 0 {
+				m.state = stateProfiles
+			}
 			return m, nil
 
 		case "tab", "shift+tab", "up", "down":
 			s := msg.String()
+			visible := m.visibleInputs()
+			current := slices.Index(visible, m.activeInput)
 
 			if s == "tab" || s == "down" {
-				m.inputs[m.activeInput].Blur()
-				m.activeInput = (m.activeInput + 1) % len(m.inputs)
-				m.inputs[m.activeInput].Focus()
+				m.focusInput(visible[(current+1)%len(visible)])
 				return m, nil
 			}
 
 			if s == "shift+tab" || s == "up" {
-				m.inputs[m.activeInput].Blur()
-				m.activeInput--
-				if m.activeInput < 0 {
-					m.activeInput = len(m.inputs) - 1
+				current--
+				if current < 0 {
+					current = len(visible) - 1
 				}
-				m.inputs[m.activeInput].Focus()
+				m.focusInput(visible[current])
 				return m, nil
 			}
 		}
@@ -104,28 +135,381 @@ func (m model) updateCredentials(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// visibleInputs returns the indexes of the form inputs shown in the
+// current mode, in display order.
+func (m *model) visibleInputs() []int {
+	if m.connMode {
+		return connStringInputs
+	}
+
+	visible := make([]int, 0, len(m.inputs))
+	for i := range m.inputs {
+		if i != inputConnString {
+			visible = append(visible, i)
+		}
+	}
+	return visible
+}
+
+func (m *model) focusInput(i int) {
+	m.inputs[m.activeInput].Blur()
+	m.activeInput = i
+	m.inputs[m.activeInput].Focus()
+}
+
+// resetForm clears the credentials form for adding a new profile.
+func (m *model) resetForm(name string) {
+	for i, field := range credentialFields {
+		m.inputs[i].SetValue(field.value)
+	}
+	m.inputs[inputName].SetValue(name)
+	m.updatePasswordEcho()
+	m.connMode = false
+	m.editingProfile = ""
+	m.formFilters = storage.Filters{}
+	m.focusInput(inputName)
+}
+
+// fillForm loads p into the credentials form for editing.
+func (m *model) fillForm(p *storage.Profile) {
+	m.resetForm(p.Name)
+
+	creds := p.Credentials
+	knownHosts := creds.SSH.KnownHostsFile
+	if creds.SSH.InsecureIgnoreHostKey {
+		knownHosts = insecureKnownHosts
+	}
+
+	values := map[int]string{
+		inputHost:          creds.Host,
+		inputPort:          creds.Port,
+		inputDatabase:      creds.Database,
+		inputUser:          creds.User,
+		inputPassword:      creds.Password,
+		inputSSLMode:       creds.SSLMode,
+		inputSSLRootCert:   creds.SSLRootCert,
+		inputSSLCert:       creds.SSLCert,
+		inputSSLKey:        creds.SSLKey,
+		inputConnString:    creds.ConnString,
+		inputSSHHost:       creds.SSH.Host,
+		inputSSHPort:       creds.SSH.Port,
+		inputSSHUser:       creds.SSH.User,
+		inputSSHKeyFile:    creds.SSH.KeyFile,
+		inputSSHKnownHosts: knownHosts,
+		inputQueryMode:     creds.QueryMode,
+		inputMaxConns:      formatCount(creds.MaxConns),
+		inputMinConns:      formatCount(creds.MinConns),
+	}
+	for i, value := range values {
+		m.inputs[i].SetValue(value)
+	}
+
+	m.updatePasswordEcho()
+
+	m.connMode = creds.ConnString != ""
+	m.editingProfile = p.Name
+	m.formFilters = p.Filters
+}
+
+// formProfile builds and validates a profile from the credentials form.
+func (m *model) formProfile() (storage.Profile, error) {
+	value := func(i int) string {
+		return strings.TrimSpace(m.inputs[i].Value())
+	}
+
+	profile := storage.Profile{
+		Name:    value(inputName),
+		Filters: m.formFilters,
+	}
+	if profile.Name == "" {
+		return profile, storage.ErrProfileName
+	}
+
+	tunnel := storage.SSHTunnel{
+		Host:           value(inputSSHHost),
+		Port:           value(inputSSHPort),
+		User:           value(inputSSHUser),
+		KeyFile:        value(inputSSHKeyFile),
+		KnownHostsFile: value(inputSSHKnownHosts),
+	}
+	if tunnel.KnownHostsFile == insecureKnownHosts {
+		tunnel.KnownHostsFile = ""
+		tunnel.InsecureIgnoreHostKey = true
+	}
+
+	maxConns, err := parseCount(value(inputMaxConns))
+	if err != nil {
+		return profile, fmt.Errorf("max connections: %w", err)
+	}
+	minConns, err := parseCount(value(inputMinConns))
+	if err != nil {
+		return profile, fmt.Errorf("min connections: %w", err)
+	}
+
+	if m.connMode {
+		connString := value(inputConnString)
+		if err := postgres.ValidateConnString(connString); err != nil {
+			return profile, err
+		}
+		profile.Credentials = storage.Credentials{
+			ConnString: connString,
+			SSH:        tunnel,
+			QueryMode:  value(inputQueryMode),
+			MaxConns:   maxConns,
+			MinConns:   minConns,
+		}
+		return profile, profile.Credentials.Validate()
+	}
+
+	profile.Credentials = storage.Credentials{
+		Host:        m.inputs[inputHost].Value(),
+		Port:        m.inputs[inputPort].Value(),
+		Database:    m.inputs[inputDatabase].Value(),
+		User:        m.inputs[inputUser].Value(),
+		Password:    m.inputs[inputPassword].Value(),
+		SSLMode:     value(inputSSLMode),
+		SSLRootCert: value(inputSSLRootCert),
+		SSLCert:     value(inputSSLCert),
+		SSLKey:      value(inputSSLKey),
+		SSH:         tunnel,
+		QueryMode:   value(inputQueryMode),
+		MaxConns:    maxConns,
+		MinConns:    minConns,
+	}
+	return profile, profile.Credentials.Validate()
+}
+
+// parseCount parses an optional non-negative number, where empty means zero.
+func parseCount(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a valid number", s)
+	}
+	return n, nil
+}
+
+func formatCount(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// schemaFilter returns the filters for connecting with p, or without a
+// profile when p is nil.
+func (m *model) schemaFilter(p *storage.Profile) postgres.SchemaFilter {
+	if p != nil {
+		return m.config.SchemaFilter(p.Filters)
+	}
+	return m.config.SchemaFilter(m.sessionFilters)
+}
+
+// connectProfile makes p the active profile and starts connecting to it.
+func (m *model) connectProfile(p storage.Profile) tea.Cmd {
+	if err := m.credStore.MarkUsed(p.Name); err != nil {
+		m.err = err
+		return nil
+	}
+
+	if m.client != nil {
+		m.client.Close()
+	}
+
+	m.profile = &p
+	m.schemas = nil
+	m.client = nil
+	m.cursor = cursor{schema: 0, table: -1, column: -1}
+	m.state = stateLoading
+	return connectToDB(&p.Credentials, m.schemaFilter(&p), m.config.Timeouts)
+}
+
+func (m *model) setProfiles(profiles []storage.Profile, lastUsed string) {
+	m.profiles = profiles
+	m.confirmDelete = false
+
+	if i := slices.IndexFunc(profiles, func(p storage.Profile) bool { return p.Name == lastUsed }); i != -1 {
+		m.profileCursor = i
+	}
+	if m.profileCursor >= len(profiles) {
+		m.profileCursor = len(profiles) - 1
+	}
+	if m.profileCursor < 0 {
+		m.profileCursor = 0
+	}
+}
+
+func (m *model) reloadProfiles() error {
+	profiles, lastUsed, err := m.credStore.Profiles()
+	if err != nil {
+		return err
+	}
+	m.setProfiles(profiles, lastUsed)
+	return nil
+}
+
+func (m model) updateProfiles(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	m.err = nil
+
+	if m.confirmDelete {
+		m.confirmDelete = false
+		if keyMsg.String() != "y" {
+			m.message = "Delete cancelled"
+			return m, nil
+		}
+
+		name := m.profiles[m.profileCursor].Name
+		if err := m.credStore.DeleteProfile(name); err != nil {
+			m.err = err
+			return m, nil
+		}
+		if m.profile != nil && m.profile.Name == name {
+			m.profile = nil
+		}
+		if err := m.reloadProfiles(); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.message = fmt.Sprintf("Profile %q deleted", name)
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if m.profileCursor > 0 {
+			m.profileCursor--
+		}
+	case "down", "j":
+		if m.profileCursor < len(m.profiles)-1 {
+			m.profileCursor++
+		}
+	case "enter":
+		if len(m.profiles) > 0 {
+			return m, m.connectProfile(m.profiles[m.profileCursor])
+		}
+	case "a":
+		m.resetForm("")
+		m.state = stateCredentials
+	case "e":
+		if len(m.profiles) > 0 {
+			m.fillForm(&m.profiles[m.profileCursor])
+			m.state = stateCredentials
+		}
+	case "r":
+		if len(m.profiles) > 0 {
+			m.renameInput.SetValue(m.profiles[m.profileCursor].Name)
+			m.renameInput.Focus()
+			m.state = stateRenameProfile
+		}
+	case "x":
+		if len(m.profiles) > 0 {
+			m.confirmDelete = true
+		}
+	case "P":
+		if m.credStore.UsesPassphrase() {
+			m.message = "Credentials are already protected by a passphrase"
+			return m, nil
+		}
+		m.settingPassphrase = true
+		m.passphraseInput.Focus()
+		m.confirmInput.Blur()
+		m.state = statePassphrase
+	case "esc":
+		if m.client != nil {
+			m.state = stateExplorer
+		}
+	}
+
+	return m, nil
+}
+
+func (m model) updateRenameProfile(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.Type {
+		case tea.KeyEnter:
+			oldName := m.profiles[m.profileCursor].Name
+			newName := strings.TrimSpace(m.renameInput.Value())
+			if err := m.credStore.RenameProfile(oldName, newName); err != nil {
+				m.err = err
+				return m, nil
+			}
+			if m.profile != nil && m.profile.Name == oldName {
+				m.profile.Name = newName
+			}
+			if err := m.reloadProfiles(); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.profileCursor = slices.IndexFunc(m.profiles, func(p storage.Profile) bool { return p.Name == newName })
+			m.err = nil
+			m.message = fmt.Sprintf("Profile %q renamed to %q", oldName, newName)
+			m.state = stateProfiles
+			return m, nil
+
+		case tea.KeyEsc:
+			m.err = nil
+			m.state = stateProfiles
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.renameInput, cmd = m.renameInput.Update(msg)
+	return m, cmd
+}
+
 func (m model) updateExplorer(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "up", "k":
+		switch {
+		case key.Matches(msg, m.keys.up):
 			m.moveCursor(-1)
-		case "down", "j":
+		case key.Matches(msg, m.keys.down):
 			m.moveCursor(1)
-		case "left", "h":
+		case key.Matches(msg, m.keys.collapse):
 			m.collapse()
-		case "right", "l", "enter":
+		case key.Matches(msg, m.keys.expand):
 			m.expand()
-		case " ":
+		case key.Matches(msg, m.keys.selection):
 			m.toggleSelection()
-		case "m":
-			md := markdown.Generate(m.schemas)
-			if err := clipboard.WriteAll(md); err != nil {
+			m.countTokens()
+		case key.Matches(msg, m.keys.copy):
+			m.copySelection(m.format)
+		case key.Matches(msg, m.keys.diagram):
+			m.copySelection(export.Mermaid{})
+		case key.Matches(msg, m.keys.save):
+			m.saveInput.SetValue(m.savePath())
+			m.saveInput.CursorEnd()
+			m.saveInput.Focus()
+			m.message = ""
+			m.state = stateSave
+		case key.Matches(msg, m.keys.print):
+			out, _, err := m.render(m.format)
+			if err != nil {
 				m.err = err
 				return m, nil
 			}
-			m.message = "Markdown copied to clipboard!"
-		case "c":
+			m.output = out
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.format):
+			m.formatCursor = max(0, slices.Index(m.config.Formats, m.format))
+			m.message = ""
+			m.state = stateFormats
+		case key.Matches(msg, m.keys.budget):
+			m.budgetInput.SetValue("")
+			if m.budget > 0 {
+				m.budgetInput.SetValue(strconv.Itoa(m.budget))
+			}
+			m.budgetInput.Focus()
+			m.message = ""
+			m.state = stateBudget
+		case key.Matches(msg, m.keys.comment):
 			if m.cursor.table != -1 {
 				m.state = stateComment
 				if m.cursor.column != -1 {
@@ -135,18 +519,306 @@ func (m model) updateExplorer(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.commentInput.Focus()
 			}
-		case "d":
+		case key.Matches(msg, m.keys.deselectAll):
 			m.deselectAll()
+			m.countTokens()
 			m.message = "All items deselected!"
-		case "e":
+		case key.Matches(msg, m.keys.edit):
+			if m.profile != nil {
+				m.fillForm(m.profile)
+			} else {
+				m.resetForm("")
+			}
 			m.state = stateEditCredentials
 			m.message = "Editing connection details..."
+		case key.Matches(msg, m.keys.filters):
+			m.fillFilters()
+			m.message = ""
+			m.state = stateFilters
+		case key.Matches(msg, m.keys.profiles):
+			if err := m.reloadProfiles(); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.message = ""
+			m.state = stateProfiles
+		}
+	}
+
+	return m, nil
+}
+
+// fillFilters loads the filters of the active profile into the filter
+// screen. Lists the profile leaves empty show the configured default.
+func (m *model) fillFilters() {
+	own := m.sessionFilters
+	if m.profile != nil {
+		own = m.profile.Filters
+	}
+	cfg := m.config.Filters
+
+	lists := []struct{ own, inherited []string }{
+		filterIncludeSchemas: {own.IncludeSchemas, cfg.IncludeSchemas},
+		filterExcludeSchemas: {own.ExcludeSchemas, cfg.ExcludeSchemas},
+		filterIncludeTables:  {own.IncludeTables, cfg.IncludeTables},
+		filterExcludeTables:  {own.ExcludeTables, cfg.ExcludeTables},
+	}
+	for i, list := range lists {
+		m.filterInputs[i].SetValue(strings.Join(list.own, ", "))
+		m.filterInputs[i].Placeholder = filterFields[i].placeholder
+		if len(list.inherited) > 0 {
+			m.filterInputs[i].Placeholder = "Empty for " + strings.Join(list.inherited, ", ")
+		}
+	}
+	m.focusFilter(filterIncludeSchemas)
+}
+
+func (m *model) focusFilter(i int) {
+	m.filterInputs[m.activeFilter].Blur()
+	m.activeFilter = i
+	m.filterInputs[i].Focus()
+}
+
+func (m model) updateFilters(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.err = nil
+
+		switch msg.String() {
+		case "esc":
+			m.state = stateExplorer
+			return m, nil
+
+		case "tab", "down":
+			m.focusFilter((m.activeFilter + 1) % len(m.filterInputs))
+			return m, nil
+
+		case "shift+tab", "up":
+			m.focusFilter((m.activeFilter + len(m.filterInputs) - 1) % len(m.filterInputs))
+			return m, nil
+
+		case "enter":
+			filters := storage.Filters{
+				IncludeSchemas: config.SplitList(m.filterInputs[filterIncludeSchemas].Value()),
+				ExcludeSchemas: config.SplitList(m.filterInputs[filterExcludeSchemas].Value()),
+				IncludeTables:  config.SplitList(m.filterInputs[filterIncludeTables].Value()),
+				ExcludeTables:  config.SplitList(m.filterInputs[filterExcludeTables].Value()),
+			}
+
+			// Connections without a profile keep their filters for the session
+			if m.profile == nil {
+				m.sessionFilters = filters
+			} else {
+				p := *m.profile
+				p.Filters = filters
+				if err := m.credStore.SaveProfile(p.Name, p); err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.profile = &p
+			}
+
+			m.schemas = nil
+			m.cursor = cursor{schema: 0, table: -1, column: -1}
+			m.state = stateLoading
+			return m, loadSchemas(m.client, m.schemaFilter(m.profile), m.config.Timeouts)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.filterInputs[m.activeFilter], cmd = m.filterInputs[m.activeFilter].Update(msg)
+	return m, cmd
+}
+
+// exportOptions returns the configured export options for the connected
+// database.
+func (m *model) exportOptions() export.Options {
+	opts := m.config.ExportOptions()
+	if m.client != nil {
+		opts.Database = m.client.Database()
+		opts.ServerVersion = m.client.ServerVersion()
+	}
+	return opts
+}
+
+// render returns the selected objects in the format of e, fitted into the
+// token budget if one is set, and what was left out to fit it.
+func (m *model) render(e export.Exporter) (string, []string, error) {
+	doc := export.NewDocument(m.schemas, m.exportOptions())
+	if m.budget > 0 {
+		return export.Fit(e, doc, m.budget)
+	}
+	out, err := export.String(e, doc)
+	return out, nil, err
+}
+
+// copySelection copies the selected objects to the clipboard in the format
+// of e.
+func (m *model) copySelection(e export.Exporter) {
+	out, dropped, err := m.render(e)
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	terminal, err := m.copyToClipboard(out)
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.err = nil
+	m.message = fmt.Sprintf("Copied %s to clipboard (~%d tokens)!", e.Name(), tokens.Count(out))
+	if terminal {
+		m.message = fmt.Sprintf("Sent %s to the terminal clipboard (~%d tokens)!", e.Name(), tokens.Count(out))
+	}
+	m.message += droppedNote(dropped)
+}
+
+func droppedNote(dropped []string) string {
+	if len(dropped) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" Left out to fit the budget: %s.", strings.Join(dropped, ", "))
+}
+
+// savePath returns the path offered when saving: the file last saved to
+// with this profile or session, otherwise one named after the format.
+func (m *model) savePath() string {
+	if m.profile != nil && m.profile.ExportPath != "" {
+		return m.profile.ExportPath
+	}
+	if m.profile == nil && m.exportPath != "" {
+		return m.exportPath
+	}
+	return "schema." + m.format.Extension()
+}
+
+func (m model) updateSave(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.Type {
+		case tea.KeyEnter:
+			path := strings.TrimSpace(m.saveInput.Value())
+			if path == "" {
+				m.err = fmt.Errorf("enter a file to save to")
+				return m, nil
+			}
+			if err := m.saveSelection(path); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.state = stateExplorer
+			return m, nil
+
+		case tea.KeyEsc:
+			m.err = nil
+			m.state = stateExplorer
+			return m, nil
 		}
 	}
 
+	var cmd tea.Cmd
+	m.saveInput, cmd = m.saveInput.Update(msg)
+	return m, cmd
+}
+
+// saveSelection writes the selection in the current format to path and
+// remembers path for the next save.
+func (m *model) saveSelection(path string) error {
+	out, dropped, err := m.render(m.format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(storage.ExpandHome(path), []byte(out), 0o644); err != nil {
+		return err
+	}
+
+	m.err = nil
+	m.message = fmt.Sprintf("Saved %s to %s (~%d tokens)!", m.format.Name(), path, tokens.Count(out))
+	m.message += droppedNote(dropped)
+
+	if m.profile == nil {
+		m.exportPath = path
+		return nil
+	}
+	m.profile.ExportPath = path
+	if err := m.credStore.SetExportPath(m.profile.Name, path); err != nil {
+		m.err = fmt.Errorf("saved, but remembering the path failed: %w", err)
+	}
+	return nil
+}
+
+// countTokens updates the token count of the selection in the current
+// format.
+func (m *model) countTokens() {
+	doc := export.NewDocument(m.schemas, m.exportOptions())
+	out, err := export.String(m.format, doc)
+	if err != nil {
+		m.tokens = 0
+		return
+	}
+	m.tokens = tokens.Count(out)
+}
+
+func (m model) updateFormats(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(keyMsg, m.keys.up):
+		if m.formatCursor > 0 {
+			m.formatCursor--
+		}
+	case key.Matches(keyMsg, m.keys.down):
+		if m.formatCursor < len(m.config.Formats)-1 {
+			m.formatCursor++
+		}
+	case keyMsg.Type == tea.KeyEnter:
+		m.format = m.config.Formats[m.formatCursor]
+		m.countTokens()
+		m.message = fmt.Sprintf("Export format: %s", m.format.Name())
+		m.state = stateExplorer
+	case keyMsg.Type == tea.KeyEsc:
+		m.state = stateExplorer
+	}
 	return m, nil
 }
 
+func (m model) updateBudget(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.Type {
+		case tea.KeyEnter:
+			value := strings.TrimSpace(m.budgetInput.Value())
+			budget := 0
+			if value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					m.err = fmt.Errorf("the budget must be a whole number of tokens")
+					return m, nil
+				}
+				budget = n
+			}
+			m.budget = budget
+			m.err = nil
+			m.message = "Token budget turned off"
+			if budget > 0 {
+				m.message = fmt.Sprintf("Copies will be fitted into %d tokens", budget)
+			}
+			m.state = stateExplorer
+			return m, nil
+
+		case tea.KeyEsc:
+			m.err = nil
+			m.state = stateExplorer
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.budgetInput, cmd = m.budgetInput.Update(msg)
+	return m, cmd
+}
+
 func (m *model) getVisibleItems() []cursorPosition {
 	var items []cursorPosition
 
@@ -369,7 +1041,8 @@ func (m model) updateComment(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
-			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeouts.Query)
+			defer cancel()
 
 			// Update the comment
 			err := m.client.UpdateComment(ctx, schema, table, column, commentText)
@@ -409,6 +1082,7 @@ func (m model) updateComment(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Return to explorer state
 			m.state = stateExplorer
 			m.commentInput.Reset()
+			m.countTokens()
 			m.message = "Comment updated and verified successfully!"
 			return m, nil
 
@@ -430,12 +1104,93 @@ func (m model) updateComment(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m model) updatePassphrase(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "esc":
+			m.resetPassphraseInputs()
+			m.err = nil
+			if !m.settingPassphrase {
+				return m, tea.Quit
+			}
+			m.settingPassphrase = false
+			m.state = stateProfiles
+			return m, nil
+
+		case "tab", "shift+tab", "up", "down":
+			if m.settingPassphrase {
+				if m.passphraseInput.Focused() {
+					m.passphraseInput.Blur()
+					m.confirmInput.Focus()
+				} else {
+					m.confirmInput.Blur()
+					m.passphraseInput.Focus()
+				}
+			}
+			return m, nil
+
+		case "enter":
+			passphrase := m.passphraseInput.Value()
+
+			if !m.settingPassphrase {
+				if err := m.credStore.Unlock(passphrase); err != nil {
+					m.err = err
+					m.passphraseInput.Reset()
+					return m, nil
+				}
+				m.resetPassphraseInputs()
+				m.err = nil
+				m.state = stateLoading
+				return m, m.loadStore
+			}
+
+			if passphrase != m.confirmInput.Value() {
+				m.err = errors.New("passphrases do not match")
+				return m, nil
+			}
+			if err := m.credStore.EnablePassphrase(passphrase); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.resetPassphraseInputs()
+			m.err = nil
+			m.settingPassphrase = false
+			m.message = "Credentials are now protected by a passphrase"
+			m.state = stateProfiles
+			return m, nil
+		}
+	}
+
+	var cmds [2]tea.Cmd
+	m.passphraseInput, cmds[0] = m.passphraseInput.Update(msg)
+	m.confirmInput, cmds[1] = m.confirmInput.Update(msg)
+	return m, tea.Batch(cmds[:]...)
+}
+
+func (m *model) resetPassphraseInputs() {
+	m.passphraseInput.Reset()
+	m.confirmInput.Reset()
+	m.passphraseInput.Focus()
+	m.confirmInput.Blur()
+}
+
 func (m *model) updateInputs(msg tea.Msg) tea.Cmd {
 	cmds := make([]tea.Cmd, len(m.inputs))
 
 	for i := range m.inputs {
 		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
 	}
+	m.updatePasswordEcho()
 
 	return tea.Batch(cmds...)
 }
+
+// updatePasswordEcho shows password references such as env:PGPASS in
+// clear text, since they are not secret themselves.
+func (m *model) updatePasswordEcho() {
+	if secret.IsReference(m.inputs[inputPassword].Value()) {
+		m.inputs[inputPassword].EchoMode = textinput.EchoNormal
+	} else {
+		m.inputs[inputPassword].EchoMode = textinput.EchoPassword
+	}
+}
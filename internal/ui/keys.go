@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/kerem-kaynak/llmshark/internal/config"
+)
+
+// keyMap holds the explorer key bindings from the configuration.
+type keyMap struct {
+	up          key.Binding
+	down        key.Binding
+	expand      key.Binding
+	collapse    key.Binding
+	selection   key.Binding
+	deselectAll key.Binding
+	copy        key.Binding
+	diagram     key.Binding
+	save        key.Binding
+	print       key.Binding
+	comment     key.Binding
+	edit        key.Binding
+	profiles    key.Binding
+	filters     key.Binding
+	format      key.Binding
+	budget      key.Binding
+	quit        key.Binding
+}
+
+func newKeyMap(k config.Keys) keyMap {
+	return keyMap{
+		up:          binding(k.Up),
+		down:        binding(k.Down),
+		expand:      binding(k.Expand),
+		collapse:    binding(k.Collapse),
+		selection:   binding(k.Select),
+		deselectAll: binding(k.DeselectAll),
+		copy:        binding(k.Copy),
+		diagram:     binding(k.Diagram),
+		save:        binding(k.Save),
+		print:       binding(k.Print),
+		comment:     binding(k.Comment),
+		edit:        binding(k.Edit),
+		profiles:    binding(k.Profiles),
+		filters:     binding(k.Filters),
+		format:      binding(k.Format),
+		budget:      binding(k.Budget),
+		quit:        binding(k.Quit),
+	}
+}
+
+func binding(keys config.KeyList) key.Binding {
+	// Bubble Tea reports the space bar as " "
+	translated := make([]string, len(keys))
+	for i, k := range keys {
+		if k == "space" {
+			k = " "
+		}
+		translated[i] = k
+	}
+	return key.NewBinding(key.WithKeys(translated...))
+}
+
+// keyNames are the symbols shown in help text for keys with long names.
+var keyNames = map[string]string{
+	" ":     "space",
+	"up":    "↑",
+	"down":  "↓",
+	"left":  "←",
+	"right": "→",
+}
+
+// helpKey returns the name of the first key of b, for help text.
+func helpKey(b key.Binding) string {
+	keys := b.Keys()
+	if len(keys) == 0 {
+		return ""
+	}
+	if name, ok := keyNames[keys[0]]; ok {
+		return name
+	}
+	return keys[0]
+}
+
+// explorerHelp lists the explorer keys, with format being the name of the
+// format used when copying.
+func (k keyMap) explorerHelp(format string) string {
+	entries := []string{
+		fmt.Sprintf("%s/%s: navigate", helpKey(k.up), helpKey(k.down)),
+		fmt.Sprintf("%s: select", helpKey(k.selection)),
+		fmt.Sprintf("%s/%s: expand/collapse", helpKey(k.expand), helpKey(k.collapse)),
+		fmt.Sprintf("%s: deselect all", helpKey(k.deselectAll)),
+		fmt.Sprintf("%s: edit connection details", helpKey(k.edit)),
+		fmt.Sprintf("%s: profiles", helpKey(k.profiles)),
+		fmt.Sprintf("%s: filters", helpKey(k.filters)),
+		fmt.Sprintf("%s: copy %s", helpKey(k.copy), format),
+		fmt.Sprintf("%s: format", helpKey(k.format)),
+		fmt.Sprintf("%s: token budget", helpKey(k.budget)),
+		fmt.Sprintf("%s: copy diagram", helpKey(k.diagram)),
+		fmt.Sprintf("%s: save to file", helpKey(k.save)),
+		fmt.Sprintf("%s: print and quit", helpKey(k.print)),
+		fmt.Sprintf("%s: comment", helpKey(k.comment)),
+		fmt.Sprintf("%s: quit", helpKey(k.quit)),
+	}
+	return strings.Join(entries, " • ")
+}
@@ -5,6 +5,9 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/kerem-kaynak/llmshark/internal/config"
+	"github.com/kerem-kaynak/llmshark/internal/export"
+	"github.com/kerem-kaynak/llmshark/internal/storage"
 	"github.com/muesli/reflow/wordwrap"
 )
 
@@ -48,17 +51,30 @@ var (
 			PaddingLeft(0)
 )
 
+// applyTheme sets the colors of the styles above.
+func applyTheme(t config.Theme) {
+	titleStyle = titleStyle.Foreground(lipgloss.Color(t.Title))
+	selectedStyle = selectedStyle.Foreground(lipgloss.Color(t.Selected))
+	normalStyle = normalStyle.Foreground(lipgloss.Color(t.Normal))
+	errorStyle = errorStyle.Foreground(lipgloss.Color(t.Error))
+	infoStyle = infoStyle.Foreground(lipgloss.Color(t.Info))
+	helpStyle = helpStyle.Foreground(lipgloss.Color(t.Help))
+	inputLabelStyle = inputLabelStyle.Foreground(lipgloss.Color(t.Label))
+}
+
 func (m model) credentialsView() string {
 	var b strings.Builder
 
-	b.WriteString(titleStyle.Render("PostgreSQL Connection Details"))
+	title := "PostgreSQL Connection Details"
+	if m.editingProfile != "" {
+		title = fmt.Sprintf("Editing profile %q", m.editingProfile)
+	}
+	b.WriteString(titleStyle.Render(title))
 	b.WriteString("\n\n")
 
-	labels := []string{"Host:", "Port:", "Database:", "User:", "Password:"}
-	for i := range m.inputs {
-		label := inputLabelStyle.Render(labels[i])
-		inputView := m.inputs[i].View()
-		line := fmt.Sprintf("%-12s %s", label, inputView)
+	for _, i := range m.visibleInputs() {
+		label := inputLabelStyle.Render(fmt.Sprintf("%-18s", credentialFields[i].label))
+		line := fmt.Sprintf("%s %s", label, m.inputs[i].View())
 		b.WriteString(line + "\n")
 	}
 
@@ -66,7 +82,171 @@ func (m model) credentialsView() string {
 		b.WriteString("\n" + errorStyle.Render(wordwrap.String(m.err.Error(), m.width)))
 	}
 
-	help := "\nPress Enter to connect, Esc to cancel"
+	help := "\nPress Enter to save and connect, Ctrl+T to switch to connection string, Esc to cancel"
+	if m.connMode {
+		help = "\nPress Enter to save and connect, Ctrl+T to switch to individual fields, Esc to cancel"
+	}
+	b.WriteString(helpStyle.Render(help))
+
+	return b.String()
+}
+
+// describeCredentials summarizes where creds connect to without revealing
+// the password.
+func describeCredentials(creds storage.Credentials) string {
+	var desc string
+	switch {
+	case creds.ConnString != "":
+		desc = "connection string"
+	case creds.Service != "":
+		desc = "service " + creds.Service
+	case creds.IsSocket():
+		desc = "socket " + creds.Host
+		if creds.Database != "" {
+			desc += " " + creds.Database
+		}
+		if creds.User != "" {
+			desc = creds.User + "@" + desc
+		}
+	default:
+		desc = creds.Host
+		if creds.Port != "" {
+			desc += ":" + creds.Port
+		}
+		if creds.Database != "" {
+			desc += "/" + creds.Database
+		}
+		if creds.User != "" {
+			desc = creds.User + "@" + desc
+		}
+	}
+
+	if creds.SSH.Enabled() {
+		desc += " via " + creds.SSH.Host
+	}
+	return desc
+}
+
+func (m model) profilesView() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Connection Profiles"))
+	b.WriteString("\n\n")
+
+	if len(m.profiles) == 0 {
+		b.WriteString(normalStyle.Render("No profiles yet. Press a to add one.") + "\n")
+	}
+
+	nameWidth := 0
+	for _, p := range m.profiles {
+		nameWidth = max(nameWidth, len(p.Name))
+	}
+
+	for i, p := range m.profiles {
+		style := normalStyle
+		prefix := "  "
+		if i == m.profileCursor {
+			style = selectedStyle
+			prefix = "> "
+		}
+
+		line := fmt.Sprintf("%s%-*s  ", prefix, nameWidth, p.Name)
+		active := ""
+		if m.profile != nil && m.client != nil && m.profile.Name == p.Name {
+			active = " (connected)"
+		}
+		b.WriteString(style.Render(line) + infoStyle.Render(describeCredentials(p.Credentials)+active) + "\n")
+	}
+
+	if m.confirmDelete {
+		prompt := fmt.Sprintf("\nDelete profile %q? Press y to confirm, any other key to cancel", m.profiles[m.profileCursor].Name)
+		b.WriteString(errorStyle.Render(wordwrap.String(prompt, m.width)) + "\n")
+	} else if m.message != "" {
+		b.WriteString("\n" + infoStyle.Render(wordwrap.String(m.message, m.width)) + "\n")
+	}
+
+	if m.err != nil {
+		b.WriteString("\n" + errorStyle.Render(wordwrap.String(m.err.Error(), m.width)) + "\n")
+	}
+
+	help := "\n↑/↓: navigate • enter: connect • a: add • e: edit • r: rename • x: delete • P: protect with passphrase • esc: back • q: quit"
+	b.WriteString(helpStyle.Render(wordwrap.String(help, m.width)))
+
+	return b.String()
+}
+
+func (m model) renameProfileView() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Rename profile %q", m.profiles[m.profileCursor].Name)))
+	b.WriteString("\n\n")
+	b.WriteString(fmt.Sprintf("%-15s %s\n\n", "New name:", m.renameInput.View()))
+	b.WriteString(helpStyle.Render("Press Enter to save, Esc to cancel"))
+
+	if m.err != nil {
+		b.WriteString("\n\n" + errorStyle.Render(wordwrap.String(m.err.Error(), m.width)))
+	}
+
+	return b.String()
+}
+
+func (m model) filtersView() string {
+	var b strings.Builder
+
+	title := "Schema filters for this session"
+	if m.profile != nil {
+		title = fmt.Sprintf("Schema filters for profile %q", m.profile.Name)
+	}
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+
+	for i, field := range filterFields {
+		label := inputLabelStyle.Render(fmt.Sprintf("%-18s", field.label))
+		b.WriteString(fmt.Sprintf("%s %s\n", label, m.filterInputs[i].View()))
+	}
+
+	notes := []string{
+		"Comma-separated glob patterns such as sales_*, or regular expressions prefixed with re:",
+		"Table patterns match the table name or schema.table",
+	}
+	if flags := m.config.FlagFilters; len(flags.IncludeSchemas)+len(flags.ExcludeSchemas)+len(flags.IncludeTables)+len(flags.ExcludeTables) > 0 {
+		notes = append(notes, "Filters given on the command line take precedence")
+	}
+	b.WriteString("\n" + infoStyle.Render(wordwrap.String(strings.Join(notes, "\n"), m.width)))
+
+	if m.err != nil {
+		b.WriteString("\n\n" + errorStyle.Render(wordwrap.String(m.err.Error(), m.width)))
+	}
+
+	b.WriteString(helpStyle.Render("\n\nPress Enter to apply and reload, Tab to switch fields, Esc to cancel"))
+
+	return b.String()
+}
+
+func (m model) passphraseView() string {
+	var b strings.Builder
+
+	if m.settingPassphrase {
+		b.WriteString(titleStyle.Render("Protect credentials with a passphrase"))
+		b.WriteString("\n\n")
+		b.WriteString(wordwrap.String("The encryption key will be derived from this passphrase and the key file will be deleted. You will be asked for the passphrase every time LLMShark starts.", m.width))
+		b.WriteString("\n\n")
+		b.WriteString(inputLabelStyle.Render(fmt.Sprintf("%-12s", "Passphrase:")) + " " + m.passphraseInput.View() + "\n")
+		b.WriteString(inputLabelStyle.Render(fmt.Sprintf("%-12s", "Repeat:")) + " " + m.confirmInput.View() + "\n")
+	} else {
+		b.WriteString(titleStyle.Render("Unlock credentials"))
+		b.WriteString("\n\n")
+		b.WriteString(inputLabelStyle.Render(fmt.Sprintf("%-12s", "Passphrase:")) + " " + m.passphraseInput.View() + "\n")
+	}
+
+	if m.err != nil {
+		b.WriteString("\n" + errorStyle.Render(wordwrap.String(m.err.Error(), m.width)) + "\n")
+	}
+
+	help := "\nPress Enter to unlock, Esc to quit"
+	if m.settingPassphrase {
+		help = "\nPress Enter to save, Tab to switch fields, Esc to cancel"
+	}
 	b.WriteString(helpStyle.Render(help))
 
 	return b.String()
@@ -125,7 +305,7 @@ func (m model) explorerView() string {
 	var b strings.Builder
 
 	// Help text at the top
-	help := "↑/↓: navigate • space: select • →/←: expand/collapse • d: deselect all • e: edit connection details • m: markdown • c: comment • q: quit\n"
+	help := m.keys.explorerHelp(m.format.Name()) + "\n"
 	b.WriteString(helpStyle.Render(wordwrap.String(help, m.width)))
 	b.WriteString("\n")
 
@@ -242,6 +422,8 @@ func (m model) explorerView() string {
 		}
 	}
 
+	b.WriteString("\n" + helpStyle.Render(m.tokenStatus()))
+
 	if m.message != "" {
 		b.WriteString("\n" + infoStyle.Render(wordwrap.String(m.message, m.width)))
 	}
@@ -252,3 +434,99 @@ func (m model) explorerView() string {
 
 	return b.String()
 }
+
+// tokenStatus describes the token count of the selection against the
+// budget.
+func (m model) tokenStatus() string {
+	status := fmt.Sprintf("Selection: ~%d tokens as %s", m.tokens, m.format.Name())
+	if m.budget > 0 {
+		status += fmt.Sprintf(" • budget %d", m.budget)
+		if m.tokens > m.budget {
+			status += " (will be fitted when copying)"
+		}
+	}
+	return status
+}
+
+// formatDescriptions describe the built-in formats in the format list.
+var formatDescriptions = map[string]string{
+	"markdown": "headings and column tables",
+	"compact":  "one line per table, fewest tokens",
+	"json":     "versioned document for scripts",
+	"yaml":     "versioned document for scripts",
+	"ddl":      "CREATE statements to replay",
+	"mermaid":  "ER diagram",
+	"dbml":     "dbdiagram.io",
+	"dot":      "Graphviz graph",
+}
+
+func (m model) formatsView() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Export format"))
+	b.WriteString("\n\n")
+
+	nameWidth := 0
+	for _, e := range m.config.Formats {
+		nameWidth = max(nameWidth, len(e.Name()))
+	}
+
+	for i, e := range m.config.Formats {
+		style := normalStyle
+		prefix := "  "
+		if i == m.formatCursor {
+			style = selectedStyle
+			prefix = "> "
+		}
+
+		description := formatDescriptions[e.Name()]
+		if t, ok := e.(*export.Template); ok {
+			description = "template " + t.Path()
+		}
+		line := fmt.Sprintf("%s%-*s  ", prefix, nameWidth, e.Name())
+		b.WriteString(style.Render(line) + infoStyle.Render(description) + "\n")
+	}
+
+	help := fmt.Sprintf("\n%s/%s: navigate • enter: select • esc: back", helpKey(m.keys.up), helpKey(m.keys.down))
+	b.WriteString(helpStyle.Render(wordwrap.String(help, m.width)))
+
+	return b.String()
+}
+
+func (m model) budgetView() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Token budget"))
+	b.WriteString("\n\n")
+	b.WriteString(fmt.Sprintf("%-15s %s\n\n", "Tokens:", m.budgetInput.View()))
+	b.WriteString(helpStyle.Render("Copies larger than the budget leave out descriptions, defaults, constraint names and then columns that aren't keys until they fit."))
+	b.WriteString("\n\n")
+	b.WriteString(helpStyle.Render("Press Enter to save, Esc to cancel"))
+
+	if m.err != nil {
+		b.WriteString("\n\n" + errorStyle.Render(wordwrap.String(m.err.Error(), m.width)))
+	}
+
+	return b.String()
+}
+
+func (m model) saveView() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Save %s", m.format.Name())))
+	b.WriteString("\n\n")
+	b.WriteString(fmt.Sprintf("%-15s %s\n\n", "File:", m.saveInput.View()))
+	help := "The path is remembered for the next save."
+	if m.profile != nil {
+		help = fmt.Sprintf("The path is remembered for profile %q.", m.profile.Name)
+	}
+	b.WriteString(helpStyle.Render(help + " Existing files are overwritten."))
+	b.WriteString("\n\n")
+	b.WriteString(helpStyle.Render("Press Enter to save, Esc to cancel"))
+
+	if m.err != nil {
+		b.WriteString("\n\n" + errorStyle.Render(wordwrap.String(m.err.Error(), m.width)))
+	}
+
+	return b.String()
+}